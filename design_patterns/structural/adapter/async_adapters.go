@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+)
+
+// Async Adapters
+//
+// WindowsAdapter turns a round plug into a square one.
+// These generic adapters do the same for the *shape of an API*:
+// - Callback style ("call me back when something happens")  -> a channel you can range over.
+// - Blocking style ("wait until I'm done, no way to cancel") -> a func(ctx) you can cancel.
+// - Channel style (a producer pushing into a channel)        -> an iter.Seq for range-over-func.
+
+// SubscribeFunc is the classic callback API: onEvent is called for every event
+// until the returned unsubscribe function is called.
+type SubscribeFunc[T any] func(onEvent func(T)) (unsubscribe func())
+
+// CallbackToChan adapts a callback subscription into a channel.
+// The channel is closed after ctx is cancelled, once the subscription has been removed and
+// no callback is still trying to deliver. A slow reader slows the callbacks down (backpressure)
+// unless buffer leaves room for bursts. subscribe may call back before it returns; nobody can
+// read the channel yet, so those events must fit in buffer or wait for ctx to be cancelled.
+func CallbackToChan[T any](ctx context.Context, subscribe SubscribeFunc[T], buffer int) <-chan T {
+	out := make(chan T, buffer)
+	done := make(chan struct{})
+
+	var (
+		mu       sync.Mutex
+		stopped  bool
+		inflight sync.WaitGroup
+	)
+	stop := sync.OnceFunc(func() {
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		close(done) // release callbacks blocked on a full channel
+	})
+	// Registered before subscribing, so a callback blocked inside subscribe can still be
+	// released. No goroutine waits for it, so nothing is left behind if subscribe panics.
+	release := context.AfterFunc(ctx, stop)
+	subscribed := false
+	defer func() {
+		if !subscribed {
+			release()
+		}
+	}()
+
+	unsubscribe := subscribe(func(v T) {
+		// A legacy API may keep calling us after unsubscribe; never touch out once it's closing.
+		mu.Lock()
+		if stopped {
+			mu.Unlock()
+			return
+		}
+		inflight.Add(1)
+		mu.Unlock()
+		defer inflight.Done()
+
+		select {
+		case out <- v:
+		case <-done:
+		}
+	})
+	subscribed = true
+
+	go func() {
+		<-ctx.Done()
+		stop() // AfterFunc may not have run yet
+		unsubscribe()
+		inflight.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// WithContext adapts a blocking call that knows nothing about context.
+// If ctx is cancelled first, the caller gets ctx.Err() straight away and the result is
+// dropped. The blocking call can't be stopped, so its goroutine lives as long as the call does.
+func WithContext[T any](blocking func() (T, error)) func(ctx context.Context) (T, error) {
+	return func(ctx context.Context) (T, error) {
+		type result struct {
+			v   T
+			err error
+		}
+		if err := ctx.Err(); err != nil {
+			var zero T
+			return zero, err
+		}
+		ch := make(chan result, 1) // buffered, so the abandoned goroutine can always exit
+
+		go func() {
+			v, err := blocking()
+			ch <- result{v, err}
+		}()
+
+		select {
+		case r := <-ch:
+			return r.v, r.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// ChanToSeq adapts a channel into an iterator for range-over-func loops.
+// Iteration stops when the channel is closed, ctx is cancelled, or the loop body breaks.
+func ChanToSeq[T any](ctx context.Context, ch <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok || !yield(v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// -- Legacy APIs to adapt --
+
+// LegacyUSBHub reports plugged-in devices through a callback, from its own goroutine.
+type LegacyUSBHub struct {
+	Devices []string
+}
+
+func (h *LegacyUSBHub) OnPlug(callback func(device string)) (unsubscribe func()) {
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				callback(h.Devices[i%len(h.Devices)])
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }
+}
+
+// ScanDrives is slow and has no way to be cancelled.
+func (w *WindowsMachine) ScanDrives() ([]string, error) {
+	fmt.Println("Windows Machine: Scanning drives (this takes a while)...")
+	time.Sleep(200 * time.Millisecond)
+	return []string{`C:\`, `D:\`}, nil
+}
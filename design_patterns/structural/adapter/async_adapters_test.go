@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncSource delivers its events from inside subscribe, before it returns, and keeps the
+// callback so a test can fire more events after unsubscribe, like a sloppy legacy API.
+type syncSource struct {
+	events []int
+
+	mu           sync.Mutex
+	callback     func(int)
+	unsubscribed bool
+}
+
+func (s *syncSource) subscribe(onEvent func(int)) func() {
+	s.mu.Lock()
+	s.callback = onEvent
+	s.mu.Unlock()
+	for _, e := range s.events {
+		onEvent(e)
+	}
+	return func() {
+		s.mu.Lock()
+		s.unsubscribed = true
+		s.mu.Unlock()
+	}
+}
+
+func collect(t *testing.T, ch <-chan int) []int {
+	t.Helper()
+	var got []int
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return got
+			}
+			got = append(got, v)
+		case <-timeout:
+			t.Fatalf("channel never closed; got %v so far", got)
+		}
+	}
+}
+
+func TestCallbackToChanSynchronousDelivery(t *testing.T) {
+	src := &syncSource{events: []int{1, 2, 3}}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := CallbackToChan(ctx, src.subscribe, len(src.events))
+	cancel()
+
+	if got := collect(t, ch); !slices.Equal(got, src.events) {
+		t.Errorf("got %v, want %v", got, src.events)
+	}
+	if !src.unsubscribed {
+		t.Error("never unsubscribed")
+	}
+}
+
+func TestCallbackToChanSynchronousOverflowIsReleasedByCancel(t *testing.T) {
+	src := &syncSource{events: []int{1, 2, 3, 4, 5}}
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan (<-chan int))
+	go func() { returned <- CallbackToChan(ctx, src.subscribe, 2) }()
+
+	// Three events don't fit and nobody can read yet: subscribe is stuck until ctx goes.
+	select {
+	case <-returned:
+		t.Fatal("returned although subscribe should be blocked")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+
+	select {
+	case ch := <-returned:
+		if got := collect(t, ch); !slices.Equal(got, []int{1, 2}) {
+			t.Errorf("got %v, want the two buffered events", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock: CallbackToChan never returned after cancel")
+	}
+}
+
+func TestCallbackToChanIgnoresLateCallbacks(t *testing.T) {
+	src := &syncSource{}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := CallbackToChan(ctx, src.subscribe, 0)
+	cancel()
+	collect(t, ch)
+
+	// The channel is closed; a send would panic.
+	src.mu.Lock()
+	callback := src.callback
+	src.mu.Unlock()
+	for i := range 10 {
+		callback(i)
+	}
+}
+
+func TestCallbackToChanLegacyHub(t *testing.T) {
+	hub := &LegacyUSBHub{Devices: []string{"keyboard", "mouse"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := CallbackToChan(ctx, hub.OnPlug, 0)
+
+	var got []string
+	for v := range ch {
+		got = append(got, v)
+		if len(got) == 3 {
+			cancel()
+		}
+	}
+	if len(got) < 3 || got[0] != "keyboard" || got[1] != "mouse" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCallbackToChanBackpressure(t *testing.T) {
+	src := &syncSource{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := CallbackToChan(ctx, src.subscribe, 2)
+	callback := src.callback
+
+	callback(1)
+	callback(2) // the buffer is full now
+	delivered := make(chan struct{})
+	go func() {
+		callback(3)
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+		t.Fatal("a callback didn't wait for room in a full buffer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if v := <-ch; v != 1 {
+		t.Fatalf("got %d, want 1", v)
+	}
+	select {
+	case <-delivered: // reading made room, so nothing was dropped
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback still blocked after a read")
+	}
+	cancel()
+	if got := collect(t, ch); !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("got %v, want the rest in order", got)
+	}
+}
+
+func TestCallbackToChanSubscribePanics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic was swallowed")
+			}
+		}()
+		CallbackToChan(ctx, func(func(int)) func() { panic("legacy API exploded") }, 0)
+	}()
+	cancel() // with nothing subscribed, there must be nothing left to run (or block)
+}
+
+func TestWithContext(t *testing.T) {
+	release := make(chan struct{})
+	slow := WithContext(func() (int, error) {
+		<-release
+		return 42, nil
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := slow(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	called := false
+	never := WithContext(func() (int, error) { called = true; return 1, nil })
+	if _, err := never(ctx); !errors.Is(err, context.DeadlineExceeded) || called {
+		t.Errorf("already expired: got %v, called %v", err, called)
+	}
+
+	fast := WithContext(func() (int, error) { return 7, nil })
+	if v, err := fast(context.Background()); v != 7 || err != nil {
+		t.Errorf("got %d, %v", v, err)
+	}
+}
+
+func TestChanToSeq(t *testing.T) {
+	ch := make(chan int, 5)
+	for i := range 5 {
+		ch <- i
+	}
+	close(ch)
+
+	var got []int
+	for v := range ChanToSeq(context.Background(), ch) {
+		if v == 3 {
+			break
+		}
+		got = append(got, v)
+	}
+	if !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("got %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for v := range ChanToSeq(ctx, make(chan int)) {
+		t.Errorf("got %d from a cancelled iterator", v)
+	}
+}
@@ -1,6 +1,12 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
 
 // Adapter Pattern
 //
@@ -66,4 +72,100 @@ func main() {
 	// Now we can use the "Square USB" method on the adapter
 	fmt.Println("User: Pugging Square cable into Adapter...")
 	adapter.InsertSquareUSB()
+
+	// 3. The real world version: legacy XML in, modern customers out
+	fmt.Println("\n--- Adapter Pattern: Legacy XML to JSON ---")
+	export, _ := fixtures.ReadFile("fixtures/customers.xml")
+	var source CustomerSource = &XMLtoJSONAdapter{legacy: NewLegacyCRM(export)}
+	customers, err := source.Customers()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	for _, c := range customers {
+		fmt.Printf("Customer %d: %s (%s), %d phone(s)\n", c.ID, c.Name, c.Address.City, len(c.Phones))
+	}
+
+	// Round trip: modern -> legacy XML -> modern should give back the same customers.
+	back, err := EncodeLegacyXML(customers)
+	if err == nil {
+		again, err := DecodeLegacyXML(back)
+		fmt.Println("Round trip identical:", err == nil && reflect.DeepEqual(customers, again))
+	}
+
+	// Broken exports come back as typed errors the caller can inspect.
+	for _, name := range []string{"malformed.xml", "missing_name.xml", "bad_id.xml"} {
+		data, _ := fixtures.ReadFile("fixtures/" + name)
+		_, err := DecodeLegacyXML(data)
+
+		var malformed *MalformedXMLError
+		var missing *MissingFieldError
+		var invalid *InvalidFieldError
+		switch {
+		case errors.As(err, &malformed):
+			fmt.Printf("%s: broken file at line %d\n", name, malformed.Line)
+		case errors.As(err, &missing):
+			fmt.Printf("%s: record %d has no %s\n", name, missing.Index, missing.Field)
+		case errors.As(err, &invalid):
+			fmt.Printf("%s: record %d has a bad %s (%q)\n", name, invalid.Index, invalid.Field, invalid.Value)
+		}
+	}
+
+	// 4. Adapting the *shape* of an API: callbacks, blocking calls and channels
+	fmt.Println("\n--- Adapter Pattern: Callbacks and Blocking Calls to Go Style ---")
+	hub := &LegacyUSBHub{Devices: []string{"keyboard", "mouse", "webcam"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	plugged := CallbackToChan(ctx, hub.OnPlug, 0)
+
+	// The channel becomes an iterator, so a plain range loop can consume it.
+	seen := 0
+	for device := range ChanToSeq(ctx, plugged) {
+		fmt.Println("Plugged in:", device)
+		if seen++; seen == 3 {
+			break
+		}
+	}
+	cancel()
+	for range plugged {
+		// drain until the adapter closes the channel
+	}
+	fmt.Println("Hub subscription closed.")
+
+	scan := WithContext(windowsMachine.ScanDrives)
+	quick, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	if _, err := scan(quick); err != nil {
+		fmt.Println("Scan gave up:", err)
+	}
+	drives, err := scan(context.Background())
+	fmt.Println("Scan finished:", drives, err)
+
+	// 5. Let a registry find the adapters instead of wiring them by hand
+	fmt.Println("\n--- Adapter Pattern: The Adapter Registry ---")
+	registry := NewAdapterRegistry()
+	RegisterAdapter(registry, "windows->computer", func(w *WindowsMachine) (Computer, error) {
+		return &WindowsAdapter{windowMachine: w}, nil
+	})
+	if computer, err := Resolve[Computer](registry, windowsMachine); err == nil {
+		computer.InsertSquareUSB()
+	}
+
+	RegisterAdapter(registry, "linux->round", func(l *LinuxBox) (RoundPort, error) {
+		return &MicroToRoundAdapter{box: l}, nil
+	})
+	RegisterAdapter(registry, "round->computer", func(p RoundPort) (Computer, error) {
+		return &RoundToSquareAdapter{port: p}, nil
+	})
+	if computer, err := Resolve[Computer](registry, &LinuxBox{}); err == nil {
+		fmt.Println("Registry: Found a two-step chain for the Linux Box.")
+		computer.InsertSquareUSB()
+	}
+
+	// The Windows Machine is a RoundPort too, so now there are two equally short ways in.
+	if _, err := Resolve[Computer](registry, windowsMachine); err != nil {
+		fmt.Println("Registry:", err)
+	}
+	if _, err := Resolve[Computer](registry, "a toaster"); err != nil {
+		fmt.Println("Registry:", err)
+	}
 }
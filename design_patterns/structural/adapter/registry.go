@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Adapter Registry
+//
+// In main() we build the WindowsAdapter by hand because we know we need it.
+// A plugin host doesn't know that in advance: a third-party driver shows up and the host
+// has to figure out how to plug it in. So adapters register themselves
+// ("I can turn an X into a Y"), and Resolve finds the shortest chain of adapters
+// that makes a value fit the interface we want, like stacking travel plugs.
+
+// AdapterRegistry holds every known "from X to interface Y" adapter.
+// It is safe to register and resolve from several goroutines at once.
+type AdapterRegistry struct {
+	mu    sync.RWMutex
+	edges []adapterEdge
+}
+
+type adapterEdge struct {
+	name  string
+	from  reflect.Type
+	to    reflect.Type
+	build func(any) (any, error)
+}
+
+func NewAdapterRegistry() *AdapterRegistry {
+	return &AdapterRegistry{}
+}
+
+// RegisterAdapter teaches the registry how to turn a From into a To.
+// To must be an interface type. From may be a concrete type or an interface,
+// in which case the adapter applies to anything that implements it.
+// Like http.Handle, it panics on a programming mistake at setup time.
+func RegisterAdapter[From, To any](r *AdapterRegistry, name string, build func(From) (To, error)) {
+	to := reflect.TypeFor[To]()
+	if to.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("adapter %q: target %s is not an interface", name, to))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.edges = append(r.edges, adapterEdge{
+		name: name,
+		from: reflect.TypeFor[From](),
+		to:   to,
+		build: func(v any) (any, error) {
+			from, ok := v.(From)
+			if !ok {
+				return nil, fmt.Errorf("given a %T, not a %s: %w", v, reflect.TypeFor[From](), ErrBadAdapter)
+			}
+			out, err := build(from)
+			if err != nil {
+				return nil, err
+			}
+			if any(out) == nil {
+				return nil, fmt.Errorf("returned nil: %w", ErrBadAdapter)
+			}
+			return out, nil
+		},
+	})
+}
+
+var (
+	// ErrNotInterface means Resolve was asked for a concrete type. Adapters only ever
+	// produce interfaces, so there is nothing to search for.
+	ErrNotInterface = errors.New("target is not an interface")
+	// ErrBadAdapter means an adapter returned something the next step can't use,
+	// like nil with no error.
+	ErrBadAdapter = errors.New("adapter returned an unusable value")
+)
+
+// NoAdapterPathError means no chain of registered adapters reaches the target.
+type NoAdapterPathError struct {
+	From, To reflect.Type
+}
+
+func (e *NoAdapterPathError) Error() string {
+	return fmt.Sprintf("no adapter path from %s to %s", e.From, e.To)
+}
+
+// AmbiguousAdapterError means several different chains of the same (shortest) length exist,
+// and the registry refuses to guess which one the caller meant.
+type AmbiguousAdapterError struct {
+	From, To reflect.Type
+	Paths    [][]string
+}
+
+func (e *AmbiguousAdapterError) Error() string {
+	paths := make([]string, len(e.Paths))
+	for i, p := range e.Paths {
+		paths[i] = strings.Join(p, " -> ")
+	}
+	return fmt.Sprintf("ambiguous adapter path from %s to %s: %s", e.From, e.To, strings.Join(paths, " | "))
+}
+
+// Resolve returns v as a T, adapting it through the shortest chain of registered adapters.
+// T must be an interface type, just like the To of every adapter.
+func Resolve[T any](r *AdapterRegistry, v any) (T, error) {
+	var zero T
+	target := reflect.TypeFor[T]()
+	if target.Kind() != reflect.Interface {
+		return zero, fmt.Errorf("resolve %s: %w", target, ErrNotInterface)
+	}
+	if t, ok := v.(T); ok {
+		return t, nil
+	}
+	start := reflect.TypeOf(v)
+	if start == nil {
+		return zero, &NoAdapterPathError{To: target}
+	}
+
+	path, err := r.shortestPath(start, target)
+	if err != nil {
+		return zero, err
+	}
+	for _, e := range path {
+		if v, err = e.build(v); err != nil {
+			return zero, fmt.Errorf("adapter %q: %w", e.name, err)
+		}
+	}
+	t, ok := v.(T)
+	if !ok {
+		last := path[len(path)-1].name
+		return zero, fmt.Errorf("adapter %q: got a %T, not a %s: %w", last, v, target, ErrBadAdapter)
+	}
+	return t, nil
+}
+
+// shortestPath does a breadth-first search over types. Each step of the search keeps every
+// shortest path to every type, so that two equally short routes can be reported as ambiguous.
+func (r *AdapterRegistry) shortestPath(start, target reflect.Type) ([]adapterEdge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	visited := map[reflect.Type]bool{start: true}
+	frontier := map[reflect.Type][][]adapterEdge{start: {nil}}
+
+	for len(frontier) > 0 {
+		next := map[reflect.Type][][]adapterEdge{}
+		for typ, paths := range frontier {
+			for _, e := range r.edges {
+				if !appliesTo(e.from, typ) || visited[e.to] {
+					continue
+				}
+				for _, p := range paths {
+					next[e.to] = append(next[e.to], append(append([]adapterEdge(nil), p...), e))
+				}
+			}
+		}
+
+		var found [][]adapterEdge
+		for typ, paths := range next {
+			visited[typ] = true
+			if typ.Implements(target) {
+				found = append(found, paths...)
+			}
+		}
+		switch {
+		case len(found) == 1:
+			return found[0], nil
+		case len(found) > 1:
+			err := &AmbiguousAdapterError{From: start, To: target}
+			for _, p := range found {
+				names := make([]string, len(p))
+				for i, e := range p {
+					names[i] = e.name
+				}
+				err.Paths = append(err.Paths, names)
+			}
+			slices.SortFunc(err.Paths, slices.Compare[[]string])
+			return nil, err
+		}
+		frontier = next
+	}
+	return nil, &NoAdapterPathError{From: start, To: target}
+}
+
+// appliesTo reports whether an adapter taking `from` accepts a value of type typ.
+func appliesTo(from, typ reflect.Type) bool {
+	if from == typ {
+		return true
+	}
+	return from.Kind() == reflect.Interface && typ.Implements(from)
+}
+
+// -- A third-party driver that needs two plugs stacked together --
+
+// RoundPort is anything with a round USB port, like the WindowsMachine.
+type RoundPort interface {
+	InsertRoundUSB()
+}
+
+// LinuxBox only has a micro USB port.
+type LinuxBox struct{}
+
+func (l *LinuxBox) InsertMicroUSB() {
+	fmt.Println("Success: Micro USB connected to Linux Box.")
+}
+
+// MicroToRoundAdapter makes a LinuxBox look like a RoundPort.
+type MicroToRoundAdapter struct {
+	box *LinuxBox
+}
+
+func (m *MicroToRoundAdapter) InsertRoundUSB() {
+	fmt.Println("Adapter: Converting Round USB signal to Micro USB...")
+	m.box.InsertMicroUSB()
+}
+
+// RoundToSquareAdapter makes any RoundPort look like a Computer.
+type RoundToSquareAdapter struct {
+	port RoundPort
+}
+
+func (a *RoundToSquareAdapter) InsertSquareUSB() {
+	fmt.Println("Adapter: Converting Square USB signal to Round USB...")
+	a.port.InsertRoundUSB()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func plugRegistry() *AdapterRegistry {
+	r := NewAdapterRegistry()
+	RegisterAdapter(r, "linux->round", func(l *LinuxBox) (RoundPort, error) {
+		return &MicroToRoundAdapter{box: l}, nil
+	})
+	RegisterAdapter(r, "round->computer", func(p RoundPort) (Computer, error) {
+		return &RoundToSquareAdapter{port: p}, nil
+	})
+	return r
+}
+
+func TestResolveChain(t *testing.T) {
+	c, err := Resolve[Computer](plugRegistry(), &LinuxBox{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.(*RoundToSquareAdapter); !ok {
+		t.Errorf("got %T", c)
+	}
+}
+
+func TestResolveAmbiguous(t *testing.T) {
+	r := plugRegistry()
+	RegisterAdapter(r, "windows->computer", func(w *WindowsMachine) (Computer, error) {
+		return &WindowsAdapter{windowMachine: w}, nil
+	})
+	_, err := Resolve[Computer](r, &WindowsMachine{})
+	var ambiguous *AmbiguousAdapterError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("got %v, want *AmbiguousAdapterError", err)
+	}
+	want := [][]string{{"round->computer"}, {"windows->computer"}}
+	if !slices.EqualFunc(ambiguous.Paths, want, slices.Equal) {
+		t.Errorf("paths %v, want %v", ambiguous.Paths, want)
+	}
+}
+
+func TestResolveNoPath(t *testing.T) {
+	for _, v := range []any{"a toaster", nil} {
+		var noPath *NoAdapterPathError
+		if _, err := Resolve[Computer](plugRegistry(), v); !errors.As(err, &noPath) {
+			t.Errorf("Resolve(%v) = %v, want *NoAdapterPathError", v, err)
+		}
+	}
+}
+
+func TestResolveRejectsConcreteTargets(t *testing.T) {
+	// This used to panic inside reflect's Implements.
+	if _, err := Resolve[*RoundToSquareAdapter](plugRegistry(), &LinuxBox{}); !errors.Is(err, ErrNotInterface) {
+		t.Errorf("got %v, want ErrNotInterface", err)
+	}
+}
+
+func TestResolveAdapterReturningNil(t *testing.T) {
+	for _, broken := range []string{"linux->round", "round->computer"} {
+		r := NewAdapterRegistry()
+		RegisterAdapter(r, "linux->round", func(l *LinuxBox) (RoundPort, error) {
+			if broken == "linux->round" {
+				return nil, nil
+			}
+			return &MicroToRoundAdapter{box: l}, nil
+		})
+		RegisterAdapter(r, "round->computer", func(p RoundPort) (Computer, error) {
+			if broken == "round->computer" {
+				return nil, nil
+			}
+			return &RoundToSquareAdapter{port: p}, nil
+		})
+		_, err := Resolve[Computer](r, &LinuxBox{})
+		if !errors.Is(err, ErrBadAdapter) || !strings.Contains(err.Error(), broken) {
+			t.Errorf("%s returns nil: got %v, want ErrBadAdapter naming it", broken, err)
+		}
+	}
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	r := plugRegistry()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterAdapter(r, fmt.Sprintf("extra-%d", i), func(l *LinuxBox) (io.Closer, error) {
+				return io.NopCloser(nil), nil
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := Resolve[Computer](r, &LinuxBox{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// XML to JSON Adapter
+//
+// This is the "Real World Scenario" from main.go, for real:
+// the old CRM only knows how to export XML, the new system wants JSON-shaped Go structs.
+// XMLtoJSONAdapter sits in between so neither side has to change.
+
+//go:embed fixtures/*.xml
+var fixtures embed.FS
+
+// -- The "Client" (What the modern system wants) --
+
+// Customer is the JSON-shaped record the modern system works with.
+type Customer struct {
+	ID      int     `json:"id"`
+	Status  string  `json:"status"`
+	Name    string  `json:"name"`
+	Email   string  `json:"email,omitempty"`
+	Address Address `json:"address"`
+	Phones  []Phone `json:"phones,omitempty"`
+}
+
+type Address struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+type Phone struct {
+	Type   string `json:"type"`
+	Number string `json:"number"`
+}
+
+// CustomerSource is what the modern system expects to talk to.
+type CustomerSource interface {
+	Customers() ([]Customer, error)
+}
+
+// -- The "Service" (What we have, but it doesn't fit) --
+
+// LegacyCRM can only export its customers as an XML document.
+type LegacyCRM struct {
+	export []byte
+}
+
+func NewLegacyCRM(export []byte) *LegacyCRM {
+	return &LegacyCRM{export: export}
+}
+
+func (l *LegacyCRM) ExportXML() ([]byte, error) {
+	return l.export, nil
+}
+
+// The legacy XML layout. Attributes, nested elements and repeated elements all live here
+// and nowhere else, so the modern structs above never have to know XML exists.
+type xmlCustomers struct {
+	XMLName   xml.Name      `xml:"customers"`
+	Exported  string        `xml:"exported,attr,omitempty"`
+	Customers []xmlCustomer `xml:"customer"`
+}
+
+type xmlCustomer struct {
+	ID      string     `xml:"id,attr"`
+	Status  string     `xml:"status,attr"`
+	Name    string     `xml:"name"`
+	Email   string     `xml:"email,omitempty"`
+	Address xmlAddress `xml:"address"`
+	Phones  []xmlPhone `xml:"phone"`
+}
+
+type xmlAddress struct {
+	Street string `xml:"street"`
+	City   string `xml:"city"`
+}
+
+type xmlPhone struct {
+	Type   string `xml:"type,attr"`
+	Number string `xml:",chardata"`
+}
+
+// -- Typed errors, so callers can tell "broken file" from "bad record" --
+
+// MalformedXMLError means the legacy export is not valid XML at all.
+type MalformedXMLError struct {
+	Line int
+	Err  error
+}
+
+func (e *MalformedXMLError) Error() string {
+	return fmt.Sprintf("legacy export is malformed XML (line %d): %v", e.Line, e.Err)
+}
+
+func (e *MalformedXMLError) Unwrap() error { return e.Err }
+
+// MissingFieldError means a record is missing something the modern system requires.
+type MissingFieldError struct {
+	Index int // position of the <customer> element, starting at 0
+	Field string
+}
+
+func (e *MissingFieldError) Error() string {
+	return fmt.Sprintf("customer #%d: missing required field %q", e.Index, e.Field)
+}
+
+// InvalidFieldError means a field is present but can't be converted.
+type InvalidFieldError struct {
+	Index int
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *InvalidFieldError) Error() string {
+	return fmt.Sprintf("customer #%d: invalid %s %q: %v", e.Index, e.Field, e.Value, e.Err)
+}
+
+func (e *InvalidFieldError) Unwrap() error { return e.Err }
+
+// -- The Adapter --
+
+// XMLtoJSONAdapter makes a LegacyCRM look like a CustomerSource.
+type XMLtoJSONAdapter struct {
+	legacy *LegacyCRM
+}
+
+func (a *XMLtoJSONAdapter) Customers() ([]Customer, error) {
+	fmt.Println("Adapter: Translating legacy XML into modern customers...")
+	data, err := a.legacy.ExportXML()
+	if err != nil {
+		return nil, err
+	}
+	return DecodeLegacyXML(data)
+}
+
+// JSON is a convenience for systems that want the bytes rather than the structs.
+func (a *XMLtoJSONAdapter) JSON() ([]byte, error) {
+	customers, err := a.Customers()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(customers, "", "  ")
+}
+
+// DecodeLegacyXML converts a legacy export into modern customers.
+func DecodeLegacyXML(data []byte) ([]Customer, error) {
+	var doc xmlCustomers
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		var syntaxErr *xml.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, &MalformedXMLError{Line: syntaxErr.Line, Err: err}
+		}
+		return nil, &MalformedXMLError{Err: err}
+	}
+
+	customers := make([]Customer, 0, len(doc.Customers))
+	for i, xc := range doc.Customers {
+		if xc.ID == "" {
+			return nil, &MissingFieldError{Index: i, Field: "id"}
+		}
+		id, err := strconv.Atoi(xc.ID)
+		if err != nil {
+			return nil, &InvalidFieldError{Index: i, Field: "id", Value: xc.ID, Err: err}
+		}
+		if xc.Name == "" {
+			return nil, &MissingFieldError{Index: i, Field: "name"}
+		}
+
+		c := Customer{
+			ID:      id,
+			Status:  xc.Status,
+			Name:    xc.Name,
+			Email:   xc.Email,
+			Address: Address{Street: xc.Address.Street, City: xc.Address.City},
+		}
+		for _, p := range xc.Phones {
+			c.Phones = append(c.Phones, Phone{Type: p.Type, Number: p.Number})
+		}
+		customers = append(customers, c)
+	}
+	return customers, nil
+}
+
+// EncodeLegacyXML goes the other way, so data can be written back to the old system.
+// DecodeLegacyXML(EncodeLegacyXML(c)) gives back c for any c that DecodeLegacyXML
+// could have produced. Two things don't survive the trip: XML can't tell an empty phone
+// list from a missing one, so Phones: []Phone{} comes back nil, and the export's
+// "exported" date isn't part of Customer, so it isn't written.
+func EncodeLegacyXML(customers []Customer) ([]byte, error) {
+	doc := xmlCustomers{Customers: make([]xmlCustomer, 0, len(customers))}
+	for _, c := range customers {
+		xc := xmlCustomer{
+			ID:      strconv.Itoa(c.ID),
+			Status:  c.Status,
+			Name:    c.Name,
+			Email:   c.Email,
+			Address: xmlAddress{Street: c.Address.Street, City: c.Address.City},
+		}
+		for _, p := range c.Phones {
+			xc.Phones = append(xc.Phones, xmlPhone{Type: p.Type, Number: p.Number})
+		}
+		doc.Customers = append(doc.Customers, xc)
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeLegacyXML(t *testing.T) {
+	got, err := DecodeLegacyXML(fixture(t, "customers.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Customer{
+		{
+			ID: 42, Status: "active", Name: "Ada Lovelace", Email: "ada@example.com",
+			Address: Address{Street: "12 Analytical Way", City: "London"},
+			Phones: []Phone{
+				{Type: "home", Number: "+44 20 1234 5678"},
+				{Type: "work", Number: "+44 20 8765 4321"},
+			},
+		},
+		{
+			ID: 7, Status: "suspended", Name: "Grace Hopper", Email: "grace@example.com",
+			Address: Address{Street: "1 Compiler Lane", City: "Arlington"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLegacyXMLRoundTrip(t *testing.T) {
+	customers, err := DecodeLegacyXML(fixture(t, "customers.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	customers = append(customers, Customer{ID: 0, Name: "Nobody", Phones: []Phone{}})
+
+	data, err := EncodeLegacyXML(customers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := DecodeLegacyXML(data)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, data)
+	}
+
+	// Documented: an empty phone list comes back nil.
+	customers[len(customers)-1].Phones = nil
+	if !reflect.DeepEqual(back, customers) {
+		t.Errorf("got  %+v\nwant %+v", back, customers)
+	}
+}
+
+func TestDecodeLegacyXMLErrors(t *testing.T) {
+	t.Run("malformed", func(t *testing.T) {
+		_, err := DecodeLegacyXML(fixture(t, "malformed.xml"))
+		var malformed *MalformedXMLError
+		if !errors.As(err, &malformed) || malformed.Line == 0 {
+			t.Fatalf("got %v, want a *MalformedXMLError with a line number", err)
+		}
+	})
+	t.Run("bad id", func(t *testing.T) {
+		_, err := DecodeLegacyXML(fixture(t, "bad_id.xml"))
+		var invalid *InvalidFieldError
+		if !errors.As(err, &invalid) || invalid.Index != 0 || invalid.Field != "id" || invalid.Value != "forty-two" {
+			t.Fatalf("got %v, want an *InvalidFieldError for id", err)
+		}
+		if !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("%v doesn't unwrap to strconv.ErrSyntax", err)
+		}
+	})
+	t.Run("missing name", func(t *testing.T) {
+		_, err := DecodeLegacyXML(fixture(t, "missing_name.xml"))
+		var missing *MissingFieldError
+		if !errors.As(err, &missing) || missing.Index != 0 || missing.Field != "name" {
+			t.Fatalf("got %v, want a *MissingFieldError for name", err)
+		}
+	})
+	t.Run("missing id", func(t *testing.T) {
+		_, err := DecodeLegacyXML([]byte(`<customers><customer><name>x</name></customer></customers>`))
+		var missing *MissingFieldError
+		if !errors.As(err, &missing) || missing.Field != "id" {
+			t.Fatalf("got %v, want a *MissingFieldError for id", err)
+		}
+	})
+}
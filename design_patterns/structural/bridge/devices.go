@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Device Registry
+//
+// Tv and Radio used to be the only devices in town. Now any new kind of device can join:
+// it registers a factory plus a list of what it can do ("I have channels", "I can be muted").
+// Remotes ask before pressing a button, so a speaker with no channels simply gets
+// a greyed-out Channel button instead of a confusing call.
+//
+// To see every registered device:
+//
+//	go run ./design_patterns/structural/bridge -list-devices
+
+// Capability is something a device kind can do.
+type Capability string
+
+const (
+	CapVolume   Capability = "volume"
+	CapChannels Capability = "channels"
+	CapMute     Capability = "mute"
+)
+
+// DeviceKind describes one registered kind of device.
+// Capabilities is the whole truth about what the kind can do: the listing shows it, and
+// every device New makes reports it, whatever the device itself would say.
+type DeviceKind struct {
+	Name         string
+	Description  string
+	Capabilities []Capability
+	New          func() Device
+}
+
+// CapabilityReporter is implemented by devices that know what they can do.
+// Devices that don't implement it are assumed to support everything, which keeps
+// older devices (and RemoteDevice) working exactly as before.
+type CapabilityReporter interface {
+	Capabilities() []Capability
+}
+
+// Supports reports whether device can do c.
+func Supports(device Device, c Capability) bool {
+	reporter, ok := device.(CapabilityReporter)
+	if !ok {
+		return true
+	}
+	return slices.Contains(reporter.Capabilities(), c)
+}
+
+// DeviceRegistry is the list of every device kind the remote knows how to create.
+type DeviceRegistry struct {
+	kinds map[string]DeviceKind
+}
+
+func NewDeviceRegistry() *DeviceRegistry {
+	return &DeviceRegistry{kinds: map[string]DeviceKind{}}
+}
+
+// Devices is the registry that built-in and plugin devices register with from init().
+var Devices = NewDeviceRegistry()
+
+func (r *DeviceRegistry) Register(kind DeviceKind) error {
+	if kind.Name == "" || kind.New == nil {
+		return fmt.Errorf("device kind needs a name and a factory")
+	}
+	if _, dup := r.kinds[kind.Name]; dup {
+		return fmt.Errorf("device kind %q is already registered", kind.Name)
+	}
+	kind.Capabilities = slices.Clone(kind.Capabilities)
+	r.kinds[kind.Name] = kind
+	return nil
+}
+
+// MustRegister is Register for init() functions, where a duplicate is a programming mistake.
+func (r *DeviceRegistry) MustRegister(kind DeviceKind) {
+	if err := r.Register(kind); err != nil {
+		panic(err)
+	}
+}
+
+// New creates a device of the named kind, wrapped so it reports the registered capabilities.
+func (r *DeviceRegistry) New(name string) (Device, error) {
+	kind, ok := r.kinds[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown device kind %q", name)
+	}
+	return &registeredDevice{Device: kind.New(), caps: kind.Capabilities}, nil
+}
+
+// Kinds returns every registered kind, sorted by name.
+func (r *DeviceRegistry) Kinds() []DeviceKind {
+	kinds := make([]DeviceKind, 0, len(r.kinds))
+	for _, k := range r.kinds {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].Name < kinds[j].Name })
+	return kinds
+}
+
+// List writes a table of registered devices and their capabilities.
+func (r *DeviceRegistry) List(w io.Writer) {
+	fmt.Fprintf(w, "%-10s %-26s %s\n", "DEVICE", "CAPABILITIES", "DESCRIPTION")
+	for _, k := range r.Kinds() {
+		caps := make([]string, len(k.Capabilities))
+		for i, c := range k.Capabilities {
+			caps[i] = string(c)
+		}
+		fmt.Fprintf(w, "%-10s %-26s %s\n", k.Name, strings.Join(caps, ","), k.Description)
+	}
+}
+
+// registeredDevice attaches the registered capabilities to a device, so that Tv and Radio,
+// which were written before capabilities existed, need no changes.
+type registeredDevice struct {
+	Device
+	caps []Capability
+}
+
+func (d *registeredDevice) Capabilities() []Capability {
+	return d.caps
+}
+
+// -- Built-in and "plugin" devices --
+
+func init() {
+	Devices.MustRegister(DeviceKind{
+		Name:         "tv",
+		Description:  "Television with 99 channels",
+		Capabilities: []Capability{CapVolume, CapChannels, CapMute},
+		New:          func() Device { return NewTv() },
+	})
+	Devices.MustRegister(DeviceKind{
+		Name:         "radio",
+		Description:  "Radio with 12 preset stations",
+		Capabilities: []Capability{CapVolume, CapChannels, CapMute},
+		New:          func() Device { return NewRadio() },
+	})
+	Devices.MustRegister(DeviceKind{
+		Name:         "speaker",
+		Description:  "Smart speaker, one stream, no channels",
+		Capabilities: []Capability{CapVolume, CapMute},
+		New:          func() Device { return &SmartSpeaker{volume: 30} },
+	})
+	Devices.MustRegister(DeviceKind{
+		Name:         "projector",
+		Description:  "Silent projector with 3 inputs",
+		Capabilities: []Capability{CapChannels},
+		New:          func() Device { return &Projector{input: 1} },
+	})
+}
+
+// SmartSpeaker has a volume but nothing to tune.
+type SmartSpeaker struct {
+	on     bool
+	volume int
+}
+
+func (s *SmartSpeaker) Run()            { fmt.Println("Speaker is streaming...") }
+func (s *SmartSpeaker) IsEnabled() bool { return s.on }
+func (s *SmartSpeaker) Enable()         { fmt.Println("Speaker: Turned ON"); s.on = true }
+func (s *SmartSpeaker) Disable()        { fmt.Println("Speaker: Turned OFF"); s.on = false }
+func (s *SmartSpeaker) GetVolume() int  { return s.volume }
+func (s *SmartSpeaker) SetVolume(percent int) {
+	s.volume = clampVolume(percent)
+	fmt.Printf("Speaker: Volume set to %d\n", s.volume)
+}
+func (s *SmartSpeaker) GetChannel() int        { return 1 }
+func (s *SmartSpeaker) SetChannel(channel int) {}
+
+// Projector switches between inputs but has no speaker.
+type Projector struct {
+	on    bool
+	input int
+}
+
+// projectorInputs is HDMI1, HDMI2 and VGA.
+const projectorInputs = 3
+
+func (p *Projector) Run()                  { fmt.Println("Projector is showing slides...") }
+func (p *Projector) IsEnabled() bool       { return p.on }
+func (p *Projector) Enable()               { fmt.Println("Projector: Turned ON"); p.on = true }
+func (p *Projector) Disable()              { fmt.Println("Projector: Turned OFF"); p.on = false }
+func (p *Projector) GetVolume() int        { return 0 }
+func (p *Projector) SetVolume(percent int) {}
+func (p *Projector) GetChannel() int       { return p.input }
+func (p *Projector) SetChannel(channel int) {
+	p.input = wrapChannel(channel, projectorInputs)
+	fmt.Printf("Projector: Input set to %d\n", p.input)
+}
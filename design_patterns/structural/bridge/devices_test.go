@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEnabledButtons(t *testing.T) {
+	all := []string{"Power", "Volume Up", "Volume Down", "Channel Up", "Channel Down", "Mute", "Unmute"}
+	want := map[string][]string{
+		"tv":        all,
+		"radio":     all,
+		"speaker":   {"Power", "Volume Up", "Volume Down", "Mute", "Unmute"},
+		"projector": {"Power", "Channel Up", "Channel Down"},
+	}
+	for _, kind := range Devices.Kinds() {
+		device, err := Devices.New(kind.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		remote := AdvancedRemote{BasicRemote: BasicRemote{device: device}}
+		if got := remote.EnabledButtons(); !slices.Equal(got, want[kind.Name]) {
+			t.Errorf("%s: %v, want %v", kind.Name, got, want[kind.Name])
+		}
+	}
+}
+
+// The registry decides: a remote driving a registered projector can't mute it.
+func TestRegisteredCapabilitiesAreEnforced(t *testing.T) {
+	device, err := Devices.New("projector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	projector := device.(*registeredDevice).Device.(*Projector)
+	remote := AdvancedRemote{BasicRemote: BasicRemote{device: device}}
+	remote.Mute()
+	if remote.muted {
+		t.Error("a projector got muted")
+	}
+	remote.ChannelUp()
+	if projector.GetChannel() != 2 {
+		t.Errorf("input %d, want 2", projector.GetChannel())
+	}
+
+	if !Supports(NewTv(), CapChannels) {
+		t.Error("a device without a report should support everything")
+	}
+}
+
+// reportingDevice says it can do everything, which the registry doesn't believe.
+type reportingDevice struct{ Tv }
+
+func (*reportingDevice) Capabilities() []Capability {
+	return []Capability{CapVolume, CapChannels, CapMute}
+}
+
+func TestRegistrationIsTheOnlySource(t *testing.T) {
+	r := NewDeviceRegistry()
+	built := 0
+	caps := []Capability{CapVolume}
+	r.MustRegister(DeviceKind{Name: "boaster", Capabilities: caps, New: func() Device {
+		built++
+		return &reportingDevice{}
+	}})
+	if built != 0 {
+		t.Errorf("Register built %d device(s) just to look at them", built)
+	}
+	caps[0] = CapMute // the registry keeps its own copy
+
+	device, err := r.New("boaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := r.Kinds()[0].Capabilities
+	if !slices.Equal(listed, []Capability{CapVolume}) || Supports(device, CapChannels) || !Supports(device, CapVolume) {
+		t.Errorf("listed %v, but the device supports channels=%v volume=%v", listed, Supports(device, CapChannels), Supports(device, CapVolume))
+	}
+
+	if err := r.Register(DeviceKind{Name: "boaster", New: func() Device { return &Projector{} }}); err == nil {
+		t.Error("registered the same kind twice")
+	}
+}
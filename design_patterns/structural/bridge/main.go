@@ -1,6 +1,10 @@
 package main
 
-import "fmt"
+import (
+	"flag"
+	"fmt"
+	"os"
+)
 
 // Bridge Pattern
 //
@@ -22,13 +26,40 @@ type Device interface {
 	IsEnabled() bool
 	Enable()
 	Disable()
+	GetVolume() int
 	SetVolume(percent int)
+	GetChannel() int
+	SetChannel(channel int)
+}
+
+const (
+	minVolume  = 0
+	maxVolume  = 100
+	volumeStep = 10
+)
+
+// clampVolume keeps the volume between 0 and 100, no matter what button was pressed.
+func clampVolume(percent int) int {
+	return max(minVolume, min(maxVolume, percent))
+}
+
+// wrapChannel makes channels go round in a circle: after the last one comes channel 1 again.
+func wrapChannel(channel, channels int) int {
+	return ((channel-1)%channels+channels)%channels + 1
 }
 
 // Tv is a specific device
 type Tv struct {
-	on     bool
-	volume int
+	on      bool
+	volume  int
+	channel int
+}
+
+// tvChannels is how many channels the TV can tune to.
+const tvChannels = 99
+
+func NewTv() *Tv {
+	return &Tv{volume: 30, channel: 1}
 }
 
 func (t *Tv) Run() {
@@ -49,15 +80,36 @@ func (t *Tv) Disable() {
 	t.on = false
 }
 
+func (t *Tv) GetVolume() int {
+	return t.volume
+}
+
 func (t *Tv) SetVolume(percent int) {
-	t.volume = percent
+	t.volume = clampVolume(percent)
 	fmt.Printf("TV: Volume set to %d\n", t.volume)
 }
 
+func (t *Tv) GetChannel() int {
+	return t.channel
+}
+
+func (t *Tv) SetChannel(channel int) {
+	t.channel = wrapChannel(channel, tvChannels)
+	fmt.Printf("TV: Channel set to %d\n", t.channel)
+}
+
 // Radio is another device
 type Radio struct {
-	on     bool
-	volume int
+	on      bool
+	volume  int
+	channel int
+}
+
+// radioChannels is how many preset stations the radio remembers.
+const radioChannels = 12
+
+func NewRadio() *Radio {
+	return &Radio{volume: 30, channel: 1}
 }
 
 func (r *Radio) Run() {
@@ -78,16 +130,31 @@ func (r *Radio) Disable() {
 	r.on = false
 }
 
+func (r *Radio) GetVolume() int {
+	return r.volume
+}
+
 func (r *Radio) SetVolume(percent int) {
-	r.volume = percent
+	r.volume = clampVolume(percent)
 	fmt.Printf("Radio: Volume set to %d\n", r.volume)
 }
 
+func (r *Radio) GetChannel() int {
+	return r.channel
+}
+
+func (r *Radio) SetChannel(channel int) {
+	r.channel = wrapChannel(channel, radioChannels)
+	fmt.Printf("Radio: Station set to preset %d\n", r.channel)
+}
+
 // -- Abstraction (The Remote) --
 type RemoteControl interface {
 	TogglePower()
 	VolumeDown()
 	VolumeUp()
+	ChannelDown()
+	ChannelUp()
 }
 
 // BasicRemote is a simple remote
@@ -104,27 +171,113 @@ func (r *BasicRemote) TogglePower() {
 	}
 }
 
+// enabled checks a capability before a button does anything.
+// Unsupported buttons are greyed out instead of calling methods the device can't honour.
+func (r *BasicRemote) enabled(button string, c Capability) bool {
+	if Supports(r.device, c) {
+		return true
+	}
+	fmt.Printf("Remote: %s is disabled for this device.\n", button)
+	return false
+}
+
+// EnabledButtons lists the buttons that work with the connected device.
+func (r *BasicRemote) EnabledButtons() []string {
+	buttons := []string{"Power"}
+	if Supports(r.device, CapVolume) {
+		buttons = append(buttons, "Volume Up", "Volume Down")
+	}
+	if Supports(r.device, CapChannels) {
+		buttons = append(buttons, "Channel Up", "Channel Down")
+	}
+	return buttons
+}
+
 func (r *BasicRemote) VolumeDown() {
+	if !r.enabled("Volume Down", CapVolume) {
+		return
+	}
 	fmt.Println("Remote: Volume Down pressed.")
-	r.device.SetVolume(0)
+	r.device.SetVolume(r.device.GetVolume() - volumeStep)
 }
 
 func (r *BasicRemote) VolumeUp() {
+	if !r.enabled("Volume Up", CapVolume) {
+		return
+	}
 	fmt.Println("Remote: Volume Up pressed.")
-	r.device.SetVolume(100)
+	r.device.SetVolume(r.device.GetVolume() + volumeStep)
+}
+
+func (r *BasicRemote) ChannelDown() {
+	if !r.enabled("Channel Down", CapChannels) {
+		return
+	}
+	fmt.Println("Remote: Channel Down pressed.")
+	r.device.SetChannel(r.device.GetChannel() - 1)
+}
+
+func (r *BasicRemote) ChannelUp() {
+	if !r.enabled("Channel Up", CapChannels) {
+		return
+	}
+	fmt.Println("Remote: Channel Up pressed.")
+	r.device.SetChannel(r.device.GetChannel() + 1)
 }
 
 // AdvancedRemote can do more (mute)
 type AdvancedRemote struct {
 	BasicRemote // Inherits from BasicRemote
+
+	muted       bool
+	savedVolume int // the volume to go back to on Unmute
+}
+
+// EnabledButtons adds the mute buttons when the device can be muted.
+func (r *AdvancedRemote) EnabledButtons() []string {
+	buttons := r.BasicRemote.EnabledButtons()
+	if Supports(r.device, CapMute) {
+		buttons = append(buttons, "Mute", "Unmute")
+	}
+	return buttons
 }
 
 func (r *AdvancedRemote) Mute() {
+	if !r.enabled("Mute", CapMute) {
+		return
+	}
 	fmt.Println("Advanced Remote: Mute pressed.")
-	r.device.SetVolume(0)
+	if r.muted {
+		return // don't forget the real volume by "remembering" 0
+	}
+	r.muted = true
+	r.savedVolume = r.device.GetVolume()
+	r.device.SetVolume(minVolume)
+}
+
+func (r *AdvancedRemote) Unmute() {
+	if !r.enabled("Unmute", CapMute) {
+		return
+	}
+	fmt.Println("Advanced Remote: Unmute pressed.")
+	if !r.muted {
+		return
+	}
+	r.muted = false
+	if r.device.GetVolume() != minVolume {
+		return // someone turned the volume up while muted; keep their choice
+	}
+	r.device.SetVolume(r.savedVolume)
 }
 
 func main() {
+	listDevices := flag.Bool("list-devices", false, "list registered device kinds and their capabilities, then exit")
+	flag.Parse()
+	if *listDevices {
+		Devices.List(os.Stdout)
+		return
+	}
+
 	fmt.Println("--- Bridge Pattern: Remote and Devices ---")
 
 	testDevice := func(device Device) {
@@ -132,20 +285,91 @@ func main() {
 		basicRemote := BasicRemote{device: device}
 		basicRemote.TogglePower()
 		basicRemote.VolumeUp()
+		basicRemote.ChannelDown() // wraps around to the last channel
+		basicRemote.ChannelUp()   // and back to channel 1
 		basicRemote.TogglePower()
 
 		fmt.Println("\nTests with Advanced Remote.")
-		advancedRemote := AdvancedRemote{BasicRemote{device: device}}
+		advancedRemote := AdvancedRemote{BasicRemote: BasicRemote{device: device}}
 		advancedRemote.TogglePower()
+		for i := 0; i < 8; i++ {
+			advancedRemote.VolumeUp() // stops at 100
+		}
 		advancedRemote.Mute()
+		advancedRemote.Unmute() // back to 100, not 0
 		advancedRemote.TogglePower()
+		fmt.Printf("Final state: volume=%d channel=%d on=%v\n", device.GetVolume(), device.GetChannel(), device.IsEnabled())
 	}
 
 	fmt.Println("\n--> Connecting to TV")
-	tv := &Tv{}
+	tv := NewTv()
 	testDevice(tv)
 
 	fmt.Println("\n--> Connecting to Radio")
-	radio := &Radio{}
+	radio := NewRadio()
 	testDevice(radio)
+
+	// The same bridge, for a GUI toolkit: widgets on one side, renderers on the other.
+	fmt.Println("\n--- Bridge Pattern: One Window, Many Renderers ---")
+	window := &Window{
+		Title: "Settings",
+		Children: []Widget{
+			&Label{Text: "Volume & channels"},
+			&Dialog{
+				Title:   "Save changes?",
+				Message: "Your remote settings have changed.",
+				Buttons: []*Button{{Label: "Cancel"}, {Label: "Save", Primary: true}},
+			},
+		},
+	}
+	for _, renderer := range []Renderer{&TerminalRenderer{}, &SVGRenderer{}, &HTMLRenderer{}} {
+		fmt.Printf("\n--> Rendering with %T\n", renderer)
+		window.Draw(renderer)
+		fmt.Print(renderer.Output())
+	}
+
+	// The remote doesn't care where the device is: drive a TV over TCP.
+	fmt.Println("\n--- Bridge Pattern: A Device on the Network ---")
+	server := NewDeviceServer(NewTv())
+	addr, err := server.ListenAndServe("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Server failed:", err)
+		return
+	}
+	defer server.Close()
+
+	remoteTv, err := DialDevice(addr, DialOptions{
+		Logf: func(format string, args ...any) { fmt.Printf("RemoteDevice: "+format+"\n", args...) },
+	})
+	if err != nil {
+		fmt.Println("Dial failed:", err)
+		return
+	}
+	defer remoteTv.Close()
+
+	networkRemote := BasicRemote{device: remoteTv}
+	networkRemote.TogglePower()
+	networkRemote.VolumeUp()
+
+	server.DropConnections()  // the TV's server hangs up on us...
+	networkRemote.ChannelUp() // ...and the next button press reconnects
+	fmt.Printf("Remote TV: volume=%d channel=%d on=%v err=%v\n",
+		remoteTv.GetVolume(), remoteTv.GetChannel(), remoteTv.IsEnabled(), remoteTv.Err())
+
+	// New kinds of devices come from the registry, and the remote adapts its buttons to them.
+	fmt.Println("\n--- Bridge Pattern: Devices from the Registry ---")
+	for _, kind := range Devices.Kinds() {
+		device, err := Devices.New(kind.Name)
+		if err != nil {
+			fmt.Println("Registry:", err)
+			continue
+		}
+		fmt.Printf("\n--> Connecting to %s\n", kind.Name)
+		remote := AdvancedRemote{BasicRemote: BasicRemote{device: device}}
+		fmt.Printf("Buttons: %v\n", remote.EnabledButtons())
+		remote.TogglePower()
+		remote.VolumeUp()
+		remote.ChannelUp()
+		remote.Mute()
+	}
 }
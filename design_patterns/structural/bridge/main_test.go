@@ -0,0 +1,108 @@
+package main
+
+import "testing"
+
+var devices = []struct {
+	name     string
+	new      func() Device
+	channels int
+}{
+	{"tv", func() Device { return NewTv() }, tvChannels},
+	{"radio", func() Device { return NewRadio() }, radioChannels},
+}
+
+var remotes = []struct {
+	name string
+	new  func(Device) RemoteControl
+}{
+	{"basic", func(d Device) RemoteControl { return &BasicRemote{device: d} }},
+	{"advanced", func(d Device) RemoteControl { return &AdvancedRemote{BasicRemote: BasicRemote{device: d}} }},
+}
+
+func TestRemotes(t *testing.T) {
+	for _, dev := range devices {
+		for _, rem := range remotes {
+			t.Run(rem.name+"/"+dev.name, func(t *testing.T) {
+				tests := []struct {
+					name        string
+					volume      int
+					channel     int
+					press       func(RemoteControl)
+					wantVolume  int
+					wantChannel int
+				}{
+					{"volume up", 30, 1, RemoteControl.VolumeUp, 40, 1},
+					{"volume stops at 100", 95, 1, RemoteControl.VolumeUp, 100, 1},
+					{"volume stays at 100", 100, 1, RemoteControl.VolumeUp, 100, 1},
+					{"volume stops at 0", 5, 1, RemoteControl.VolumeDown, 0, 1},
+					{"volume stays at 0", 0, 1, RemoteControl.VolumeDown, 0, 1},
+					{"channel up", 30, 1, RemoteControl.ChannelUp, 30, 2},
+					{"channel down wraps at 1", 30, 1, RemoteControl.ChannelDown, 30, dev.channels},
+					{"channel up wraps at max", 30, dev.channels, RemoteControl.ChannelUp, 30, 1},
+				}
+				for _, tt := range tests {
+					d := dev.new()
+					d.SetVolume(tt.volume)
+					d.SetChannel(tt.channel)
+					tt.press(rem.new(d))
+					if d.GetVolume() != tt.wantVolume || d.GetChannel() != tt.wantChannel {
+						t.Errorf("%s: volume %d channel %d, want %d and %d",
+							tt.name, d.GetVolume(), d.GetChannel(), tt.wantVolume, tt.wantChannel)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTogglePower(t *testing.T) {
+	for _, dev := range devices {
+		for _, rem := range remotes {
+			d := dev.new()
+			r := rem.new(d)
+			r.TogglePower()
+			if !d.IsEnabled() {
+				t.Errorf("%s/%s: still off after one press", rem.name, dev.name)
+			}
+			r.TogglePower()
+			if d.IsEnabled() {
+				t.Errorf("%s/%s: still on after two presses", rem.name, dev.name)
+			}
+		}
+	}
+}
+
+func TestMute(t *testing.T) {
+	tests := []struct {
+		name  string
+		press func(r *AdvancedRemote)
+		want  int
+	}{
+		{"mute", func(r *AdvancedRemote) { r.Mute() }, 0},
+		{"unmute restores", func(r *AdvancedRemote) { r.Mute(); r.Unmute() }, 70},
+		{"muting twice still restores", func(r *AdvancedRemote) { r.Mute(); r.Mute(); r.Unmute() }, 70},
+		{"unmute without mute", func(r *AdvancedRemote) { r.Unmute() }, 70},
+		{"volume up while muted wins", func(r *AdvancedRemote) { r.Mute(); r.VolumeUp(); r.Unmute() }, 10},
+		{"mute again after unmute", func(r *AdvancedRemote) { r.Mute(); r.Unmute(); r.Mute(); r.Unmute() }, 70},
+	}
+	for _, dev := range devices {
+		for _, tt := range tests {
+			d := dev.new()
+			d.SetVolume(70)
+			tt.press(&AdvancedRemote{BasicRemote: BasicRemote{device: d}})
+			if d.GetVolume() != tt.want {
+				t.Errorf("%s/%s: volume %d, want %d", dev.name, tt.name, d.GetVolume(), tt.want)
+			}
+		}
+	}
+}
+
+func TestWrapChannel(t *testing.T) {
+	for _, tt := range []struct{ channel, channels, want int }{
+		{1, 12, 1}, {12, 12, 12}, {13, 12, 1}, {0, 12, 12}, {-1, 12, 11}, {25, 12, 1}, {-12, 12, 12},
+	} {
+		if got := wrapChannel(tt.channel, tt.channels); got != tt.want {
+			t.Errorf("wrapChannel(%d, %d) = %d, want %d", tt.channel, tt.channels, got, tt.want)
+		}
+	}
+}
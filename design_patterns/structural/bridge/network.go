@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Network-Controlled Devices
+//
+// The remote doesn't care HOW it talks to the device, only that it is a Device.
+// So we can put the TV in another process (or another house!) and give the remote a
+// RemoteDevice that speaks a tiny text protocol over TCP. BasicRemote doesn't change at all.
+//
+// Protocol: one command per line, one reply per line.
+//
+//	POWER            toggle power          -> OK on|off
+//	POWER ON|OFF     set power             -> OK on|off
+//	VOL <0-100>      set volume            -> OK <volume>
+//	CH <n>           set channel           -> OK <channel>
+//	RUN              run the device        -> OK
+//	STATUS           read everything       -> OK power=on|off vol=<volume> ch=<channel>
+//
+// Anything that goes wrong is answered with "ERR <reason>".
+
+// -- The Server (next to the real device) --
+
+// DeviceServer exposes a Device to the network.
+type DeviceServer struct {
+	device Device
+
+	mu     sync.Mutex // guards device, ln, conns and closed
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+func NewDeviceServer(device Device) *DeviceServer {
+	return &DeviceServer{device: device, conns: map[net.Conn]struct{}{}}
+}
+
+// ListenAndServe listens on addr (use "127.0.0.1:0" for any free port) and serves in the background.
+// It returns the address actually listened on.
+func (s *DeviceServer) ListenAndServe(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // listener closed
+			}
+			s.mu.Lock()
+			if s.closed { // accepted just before Close; nobody would ever hang up on it
+				s.mu.Unlock()
+				conn.Close()
+				return
+			}
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			go s.serveConn(conn)
+		}
+	}()
+	return ln.Addr().String(), nil
+}
+
+func (s *DeviceServer) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		reply := s.handle(scanner.Text())
+		if _, err := fmt.Fprintln(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *DeviceServer) handle(line string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fields := strings.Fields(strings.ToUpper(line))
+	if len(fields) == 0 {
+		return "ERR empty command"
+	}
+	switch cmd, args := fields[0], fields[1:]; {
+	case cmd == "POWER" && len(args) == 0:
+		if s.device.IsEnabled() {
+			s.device.Disable()
+		} else {
+			s.device.Enable()
+		}
+	case cmd == "POWER" && len(args) == 1 && args[0] == "ON":
+		s.device.Enable()
+	case cmd == "POWER" && len(args) == 1 && args[0] == "OFF":
+		s.device.Disable()
+	case (cmd == "VOL" || cmd == "CH") && len(args) == 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "ERR not a number: " + args[0]
+		}
+		if cmd == "VOL" {
+			s.device.SetVolume(n)
+			return fmt.Sprintf("OK %d", s.device.GetVolume())
+		}
+		s.device.SetChannel(n)
+		return fmt.Sprintf("OK %d", s.device.GetChannel())
+	case cmd == "RUN" && len(args) == 0:
+		s.device.Run()
+		return "OK"
+	case cmd == "STATUS" && len(args) == 0:
+		return fmt.Sprintf("OK power=%s vol=%d ch=%d", onOff(s.device.IsEnabled()), s.device.GetVolume(), s.device.GetChannel())
+	default:
+		return "ERR unknown command: " + line
+	}
+	return "OK " + onOff(s.device.IsEnabled())
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// DropConnections hangs up on every connected client but keeps listening,
+// like a server restart. Clients are expected to reconnect.
+func (s *DeviceServer) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops listening and hangs up on everyone.
+func (s *DeviceServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	s.mu.Unlock()
+	s.DropConnections()
+	return err
+}
+
+// -- The Client (next to the remote) --
+
+// RemoteDevice is a Device that lives on the other end of a TCP connection.
+// Device methods can't return errors, so like bufio.Scanner the last failure is kept in Err.
+// If the connection drops, the next call dials again and retries once; every command the
+// client sends sets an absolute value, so retrying never toggles something twice.
+// A server that doesn't answer in time isn't retried: waiting twice wouldn't help.
+type RemoteDevice struct {
+	addr string
+	opts DialOptions
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	err  error
+}
+
+// DialOptions tunes a RemoteDevice. The zero value is ready to use.
+type DialOptions struct {
+	Timeout time.Duration                    // for connecting, and for each command's reply; default 5s
+	Logf    func(format string, args ...any) // told about reconnects; nil means quietly
+}
+
+// DialDevice connects to a DeviceServer.
+func DialDevice(addr string, opts DialOptions) (*RemoteDevice, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	d := &RemoteDevice{addr: addr, opts: opts}
+	if err := d.connect(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *RemoteDevice) logf(format string, args ...any) {
+	if d.opts.Logf != nil {
+		d.opts.Logf(format, args...)
+	}
+}
+
+func (d *RemoteDevice) connect() error {
+	conn, err := net.DialTimeout("tcp", d.addr, d.opts.Timeout)
+	if err != nil {
+		return err
+	}
+	d.conn, d.r = conn, bufio.NewReader(conn)
+	return nil
+}
+
+func (d *RemoteDevice) disconnect() {
+	if d.conn != nil {
+		d.conn.Close()
+		d.conn, d.r = nil, nil
+	}
+}
+
+// call sends one command and returns the text after "OK".
+func (d *RemoteDevice) call(format string, args ...any) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cmd := fmt.Sprintf(format, args...)
+	reply, err := d.roundTrip(cmd)
+	var timeout net.Error
+	switch {
+	case err == nil || errors.As(err, new(*RemoteError)):
+	case errors.As(err, &timeout) && timeout.Timeout():
+		d.disconnect() // a late reply would be read as the answer to the next command
+	default:
+		d.logf("connection lost (%v), reconnecting", err)
+		d.disconnect()
+		reply, err = d.roundTrip(cmd)
+	}
+	d.err = err
+	return reply
+}
+
+func (d *RemoteDevice) roundTrip(cmd string) (string, error) {
+	if d.conn == nil {
+		if err := d.connect(); err != nil {
+			return "", err
+		}
+	}
+	if err := d.conn.SetDeadline(time.Now().Add(d.opts.Timeout)); err != nil {
+		return "", err
+	}
+	if _, err := fmt.Fprintln(d.conn, cmd); err != nil {
+		return "", err
+	}
+	line, err := d.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSpace(line)
+	if reason, ok := strings.CutPrefix(line, "ERR "); ok {
+		return "", &RemoteError{Command: cmd, Reason: reason}
+	}
+	if line != "OK" && !strings.HasPrefix(line, "OK ") {
+		return "", &RemoteError{Command: cmd, Reason: "unexpected reply " + strconv.Quote(line)}
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "OK")), nil
+}
+
+// RemoteError is a command the server understood the transport for, but refused.
+type RemoteError struct {
+	Command string
+	Reason  string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("device refused %q: %s", e.Command, e.Reason)
+}
+
+// Err returns the error from the most recent call, or nil if it worked.
+func (d *RemoteDevice) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *RemoteDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disconnect()
+	return nil
+}
+
+// status reads one field ("power", "vol" or "ch") from a STATUS reply.
+func (d *RemoteDevice) status(field string) string {
+	for _, kv := range strings.Fields(d.call("STATUS")) {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == field {
+			return v
+		}
+	}
+	return ""
+}
+
+func (d *RemoteDevice) Run()            { d.call("RUN") }
+func (d *RemoteDevice) IsEnabled() bool { return d.status("power") == "on" }
+func (d *RemoteDevice) Enable()         { d.call("POWER ON") }
+func (d *RemoteDevice) Disable()        { d.call("POWER OFF") }
+
+func (d *RemoteDevice) GetVolume() int {
+	n, _ := strconv.Atoi(d.status("vol"))
+	return n
+}
+
+func (d *RemoteDevice) SetVolume(percent int) { d.call("VOL %d", percent) }
+
+func (d *RemoteDevice) GetChannel() int {
+	n, _ := strconv.Atoi(d.status("ch"))
+	return n
+}
+
+func (d *RemoteDevice) SetChannel(channel int) { d.call("CH %d", channel) }
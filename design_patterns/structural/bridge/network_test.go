@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, device Device) (*DeviceServer, string) {
+	t.Helper()
+	server := NewDeviceServer(device)
+	addr, err := server.ListenAndServe("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, addr
+}
+
+func dial(t *testing.T, addr string) *RemoteDevice {
+	t.Helper()
+	d, err := DialDevice(addr, DialOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestProtocol(t *testing.T) {
+	_, addr := serve(t, NewTv())
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for _, tt := range []struct{ send, want string }{
+		{"STATUS", "OK power=off vol=30 ch=1"},
+		{"POWER", "OK on"},
+		{"power off", "OK off"},
+		{"POWER ON", "OK on"},
+		{"VOL 150", "OK 100"},
+		{"CH 0", "OK 99"},
+		{"RUN", "OK"},
+		{"VOL loud", "ERR not a number: LOUD"},
+		{"", "ERR empty command"},
+		{"JUMP", "ERR unknown command: JUMP"},
+		{"STATUS", "OK power=on vol=100 ch=99"},
+	} {
+		fmt.Fprintln(conn, tt.send)
+		got, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want+"\n" {
+			t.Errorf("%q: got %q, want %q", tt.send, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteDeviceDrivesTheRealOne(t *testing.T) {
+	tv := NewTv()
+	_, addr := serve(t, tv)
+	remote := BasicRemote{device: dial(t, addr)}
+
+	remote.TogglePower()
+	remote.VolumeUp()
+	remote.ChannelDown()
+	if !tv.IsEnabled() || tv.GetVolume() != 40 || tv.GetChannel() != tvChannels {
+		t.Errorf("tv: on=%v volume=%d channel=%d", tv.IsEnabled(), tv.GetVolume(), tv.GetChannel())
+	}
+}
+
+func TestRemoteDeviceReconnects(t *testing.T) {
+	tv := NewTv()
+	server, addr := serve(t, tv)
+	d := dial(t, addr)
+	var logged []string
+	d.opts.Logf = func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+	remote := BasicRemote{device: d}
+
+	remote.TogglePower()
+	for range 3 {
+		server.DropConnections()
+		remote.VolumeUp()
+		if err := d.Err(); err != nil {
+			t.Fatalf("after a drop: %v", err)
+		}
+	}
+	if !tv.IsEnabled() || tv.GetVolume() != 60 {
+		t.Errorf("tv: on=%v volume=%d; every press should land exactly once", tv.IsEnabled(), tv.GetVolume())
+	}
+	if len(logged) != 3 || !strings.Contains(logged[0], "reconnecting") {
+		t.Errorf("logged %q; want one line per reconnect", logged)
+	}
+}
+
+func TestRemoteDeviceErrors(t *testing.T) {
+	server, addr := serve(t, NewTv())
+	d := dial(t, addr)
+
+	d.call("JUMP")
+	var refused *RemoteError
+	if !errors.As(d.Err(), &refused) || refused.Command != "JUMP" {
+		t.Fatalf("got %v, want a *RemoteError", d.Err())
+	}
+	// A refusal is not a broken connection: the next call works.
+	d.SetVolume(55)
+	if d.Err() != nil || d.GetVolume() != 55 {
+		t.Errorf("after a refusal: volume %d, err %v", d.GetVolume(), d.Err())
+	}
+
+	server.Close()
+	d.SetVolume(10)
+	if d.Err() == nil || errors.As(d.Err(), &refused) {
+		t.Errorf("with the server gone: got %v, want a connection error", d.Err())
+	}
+}
+
+// hungServer accepts connections and never answers.
+func hungServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+	return ln.Addr().String()
+}
+
+func TestRemoteDeviceTimesOut(t *testing.T) {
+	d, err := DialDevice(hungServer(t), DialOptions{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	start := time.Now()
+	d.SetVolume(10)
+	var netErr net.Error
+	if !errors.As(d.Err(), &netErr) || !netErr.Timeout() {
+		t.Fatalf("got %v, want a timeout", d.Err())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v; a hung server should cost one timeout", elapsed)
+	}
+}
+
+func TestDialDeviceTimesOut(t *testing.T) {
+	// Nothing routes to this address (TEST-NET-1), so the dial can only end by timing out.
+	start := time.Now()
+	_, err := DialDevice("192.0.2.1:9", DialOptions{Timeout: 50 * time.Millisecond})
+	if err == nil {
+		t.Fatal("dialed a black hole")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v", elapsed)
+	}
+}
+
+func TestServerCloseHangsUp(t *testing.T) {
+	server, addr := serve(t, NewTv())
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, "STATUS") // make sure the server has picked the connection up
+	r := bufio.NewReader(conn)
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Error("connection still open after Close")
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Error("still listening after Close")
+	}
+}
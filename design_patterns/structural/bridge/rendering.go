@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Cross-Platform Rendering Bridge
+//
+// The remote/device example again, but with the GUI toolkit from the header comment:
+// - Abstraction side: Window, Dialog and Button know WHAT to show.
+// - Implementation side: a Renderer knows HOW to draw it (terminal, SVG or HTML).
+// Any widget can be drawn by any renderer, and adding a new one of either never touches the other.
+
+// -- Implementation (The Renderer) --
+type Renderer interface {
+	OpenFrame(kind, title string) // start a window or dialog; frames can nest
+	CloseFrame()
+	Text(text string)
+	Button(label string, primary bool)
+	Output() string
+}
+
+// -- Abstraction (The Widgets) --
+type Widget interface {
+	Draw(r Renderer)
+}
+
+// Window is a top-level frame holding other widgets.
+type Window struct {
+	Title    string
+	Children []Widget
+}
+
+func (w *Window) Draw(r Renderer) {
+	r.OpenFrame("window", w.Title)
+	for _, child := range w.Children {
+		child.Draw(r)
+	}
+	r.CloseFrame()
+}
+
+// Dialog is a small frame with a message and a row of buttons.
+type Dialog struct {
+	Title   string
+	Message string
+	Buttons []*Button
+}
+
+func (d *Dialog) Draw(r Renderer) {
+	r.OpenFrame("dialog", d.Title)
+	r.Text(d.Message)
+	for _, b := range d.Buttons {
+		b.Draw(r)
+	}
+	r.CloseFrame()
+}
+
+// Label is plain text.
+type Label struct {
+	Text string
+}
+
+func (l *Label) Draw(r Renderer) {
+	r.Text(l.Text)
+}
+
+// Button is something you can click. The primary button is the default action.
+type Button struct {
+	Label   string
+	Primary bool
+}
+
+func (b *Button) Draw(r Renderer) {
+	r.Button(b.Label, b.Primary)
+}
+
+// -- Concrete Renderers --
+
+// TerminalRenderer draws with indentation and ANSI escape codes.
+type TerminalRenderer struct {
+	out   strings.Builder
+	depth int
+}
+
+const (
+	ansiBold    = "\x1b[1m"
+	ansiReverse = "\x1b[7m"
+	ansiReset   = "\x1b[0m"
+)
+
+func (t *TerminalRenderer) line(format string, args ...any) {
+	t.out.WriteString(strings.Repeat("│ ", t.depth))
+	fmt.Fprintf(&t.out, format, args...)
+	t.out.WriteString("\n")
+}
+
+func (t *TerminalRenderer) OpenFrame(kind, title string) {
+	t.line("┌─ %s%s%s (%s)", ansiBold, title, ansiReset, kind)
+	t.depth++
+}
+
+func (t *TerminalRenderer) CloseFrame() {
+	t.depth--
+	t.line("└─")
+}
+
+func (t *TerminalRenderer) Text(text string) {
+	t.line("%s", text)
+}
+
+func (t *TerminalRenderer) Button(label string, primary bool) {
+	if primary {
+		t.line("%s[ %s ]%s", ansiReverse, label, ansiReset)
+		return
+	}
+	t.line("[ %s ]", label)
+}
+
+func (t *TerminalRenderer) Output() string {
+	return t.out.String()
+}
+
+// SVGRenderer lays widgets out top to bottom as an SVG image.
+type SVGRenderer struct {
+	body   strings.Builder
+	y      int
+	frames []int // y position where each open frame started
+}
+
+const (
+	svgWidth      = 320
+	svgLineHeight = 24
+	svgIndent     = 12
+)
+
+func (s *SVGRenderer) x() int {
+	return svgIndent * (len(s.frames) + 1)
+}
+
+func (s *SVGRenderer) OpenFrame(kind, title string) {
+	s.frames = append(s.frames, s.y)
+	s.y += svgLineHeight
+	fmt.Fprintf(&s.body, "  <text class=%q x=\"%d\" y=\"%d\" font-weight=\"bold\">%s</text>\n",
+		kind, s.x(), s.y-6, html.EscapeString(title))
+}
+
+func (s *SVGRenderer) CloseFrame() {
+	top := s.frames[len(s.frames)-1]
+	s.frames = s.frames[:len(s.frames)-1]
+	s.y += svgLineHeight / 2
+	x := svgIndent * len(s.frames)
+	// Drawn last with no fill, so it outlines the children instead of covering them.
+	fmt.Fprintf(&s.body, "  <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"none\" stroke=\"black\"/>\n",
+		x+svgIndent/2, top+2, svgWidth-2*x-svgIndent, s.y-top-4)
+}
+
+func (s *SVGRenderer) Text(text string) {
+	s.y += svgLineHeight
+	fmt.Fprintf(&s.body, "  <text x=\"%d\" y=\"%d\">%s</text>\n", s.x(), s.y-6, html.EscapeString(text))
+}
+
+func (s *SVGRenderer) Button(label string, primary bool) {
+	s.y += svgLineHeight
+	fill := "white"
+	if primary {
+		fill = "lightblue"
+	}
+	fmt.Fprintf(&s.body, "  <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"4\" fill=%q stroke=\"black\"/>\n",
+		s.x(), s.y-svgLineHeight+2, 8*len(label)+16, svgLineHeight-4, fill)
+	fmt.Fprintf(&s.body, "  <text x=\"%d\" y=\"%d\">%s</text>\n", s.x()+8, s.y-6, html.EscapeString(label))
+}
+
+func (s *SVGRenderer) Output() string {
+	return fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n%s</svg>\n",
+		svgWidth, s.y, s.body.String())
+}
+
+// HTMLRenderer writes a static HTML fragment.
+type HTMLRenderer struct {
+	out   strings.Builder
+	depth int
+	tags  []string
+}
+
+func (h *HTMLRenderer) line(format string, args ...any) {
+	h.out.WriteString(strings.Repeat("  ", h.depth))
+	fmt.Fprintf(&h.out, format, args...)
+	h.out.WriteString("\n")
+}
+
+func (h *HTMLRenderer) OpenFrame(kind, title string) {
+	tag := "section"
+	if kind == "dialog" {
+		tag = "dialog open"
+	}
+	h.line("<%s class=%q>", tag, kind)
+	h.tags = append(h.tags, strings.Fields(tag)[0])
+	h.depth++
+	h.line("<h1>%s</h1>", html.EscapeString(title))
+}
+
+func (h *HTMLRenderer) CloseFrame() {
+	h.depth--
+	tag := h.tags[len(h.tags)-1]
+	h.tags = h.tags[:len(h.tags)-1]
+	h.line("</%s>", tag)
+}
+
+func (h *HTMLRenderer) Text(text string) {
+	h.line("<p>%s</p>", html.EscapeString(text))
+}
+
+func (h *HTMLRenderer) Button(label string, primary bool) {
+	if primary {
+		h.line("<button class=\"primary\">%s</button>", html.EscapeString(label))
+		return
+	}
+	h.line("<button>%s</button>", html.EscapeString(label))
+}
+
+func (h *HTMLRenderer) Output() string {
+	return h.out.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden with the current output")
+
+func TestRenderGolden(t *testing.T) {
+	widgets := map[string]Widget{
+		"label":          &Label{Text: `Tom & Jerry's <b>remote</b>`},
+		"button":         &Button{Label: "OK"},
+		"primary_button": &Button{Label: "Save", Primary: true},
+		"dialog": &Dialog{
+			Title:   "Save changes?",
+			Message: "Your remote settings have changed.",
+			Buttons: []*Button{{Label: "Cancel"}, {Label: "Save", Primary: true}},
+		},
+		"window": &Window{
+			Title: "Settings",
+			Children: []Widget{
+				&Label{Text: "Volume & channels"},
+				&Dialog{
+					Title:   "Save changes?",
+					Message: "Your remote settings have changed.",
+					Buttons: []*Button{{Label: "Cancel"}, {Label: "Save", Primary: true}},
+				},
+			},
+		},
+	}
+	renderers := map[string]func() Renderer{
+		"terminal": func() Renderer { return &TerminalRenderer{} },
+		"svg":      func() Renderer { return &SVGRenderer{} },
+		"html":     func() Renderer { return &HTMLRenderer{} },
+	}
+
+	for wname, w := range widgets {
+		for rname, newRenderer := range renderers {
+			t.Run(wname+"/"+rname, func(t *testing.T) {
+				r := newRenderer()
+				w.Draw(r)
+				got := r.Output()
+
+				golden := filepath.Join("testdata", wname+"_"+rname+".golden")
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("%v (run go test -update to create it)", err)
+				}
+				if got != string(want) {
+					t.Errorf("output differs from %s (run go test -update if that's intended)\ngot:\n%s\nwant:\n%s", golden, got, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Saving and Loading Trees
+//
+// Component is an interface, so JSON alone can't tell a File from a Folder.
+// Every node is written with a "type" tag first:
+//
+//	{"type":"folder","name":"Folder1","children":[{"type":"file","name":"File1","content":"..."}]}
+//
+// Folders are built in. Leaf types are looked up in a TypeRegistry, so a new kind of leaf
+// (like Link below) joins the round trip by registering itself. Its own fields are written
+// with its json tags, right next to "type". YAML uses exactly the same shape.
+
+// ErrTooDeep is returned when the input nests folders deeper than Codec.MaxDepth.
+var ErrTooDeep = errors.New("tree nested too deeply")
+
+// UnknownTypeError is returned for a "type" that isn't "folder" or a registered leaf,
+// or when encoding a leaf whose Go type isn't registered.
+type UnknownTypeError struct {
+	Path string
+	Type string
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("%s: unknown component type %q", e.Path, e.Type)
+}
+
+// -- Type Registry --
+
+// TypeRegistry knows the leaf types that can be saved and loaded.
+type TypeRegistry struct {
+	byName map[string]func() Component
+	byType map[reflect.Type]string
+}
+
+// NewTypeRegistry returns a registry that knows "file".
+func NewTypeRegistry() *TypeRegistry {
+	r := &TypeRegistry{byName: map[string]func() Component{}, byType: map[reflect.Type]string{}}
+	RegisterType[File](r, "file")
+	return r
+}
+
+// RegisterType teaches r a leaf type, stored as {"type": name, ...}:
+//
+//	RegisterType[Link](types, "link")
+func RegisterType[T any, P interface {
+	*T
+	Component
+}](r *TypeRegistry, name string) error {
+	if name == "folder" {
+		return errors.New(`"folder" is built in`)
+	}
+	if _, ok := r.byName[name]; ok {
+		return fmt.Errorf("type %q is already registered", name)
+	}
+	r.byName[name] = func() Component { return P(new(T)) }
+	r.byType[reflect.TypeFor[P]()] = name
+	return nil
+}
+
+// -- Codec --
+
+// Codec reads and writes trees. The zero value knows folders and files.
+type Codec struct {
+	Types    *TypeRegistry // leaf types; nil means NewTypeRegistry()
+	MaxDepth int           // deepest nesting Decode accepts, the top is depth 1; default 64
+}
+
+func (c Codec) types() *TypeRegistry {
+	if c.Types == nil {
+		return NewTypeRegistry()
+	}
+	return c.Types
+}
+
+func (c Codec) EncodeJSON(root Component) ([]byte, error) {
+	obj, err := c.encode(root, "")
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(obj, "", "  ")
+}
+
+func (c Codec) EncodeYAML(root Component) ([]byte, error) {
+	obj, err := c.encode(root, "")
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(obj)
+}
+
+func (c Codec) DecodeJSON(data []byte) (Component, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the tree")
+	}
+	return c.decode(v, "", 1)
+}
+
+func (c Codec) DecodeYAML(data []byte) (Component, error) {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return c.decode(v, "", 1)
+}
+
+// object is a JSON/YAML object that keeps its keys in order, so "type" always comes first.
+type object []field
+
+type field struct {
+	Key   string
+	Value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Key)
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o object) MarshalYAML() (any, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range o {
+		var value yaml.Node
+		if err := value.Encode(yamlValue(f.Value)); err != nil {
+			return nil, err
+		}
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.Key}, &value)
+	}
+	return n, nil
+}
+
+// yamlValue turns the json.Numbers that encode keeps (so big integers survive JSON)
+// into Go numbers. yaml.v3 would write a json.Number as a quoted string, which then
+// can't be decoded back into a number field.
+func yamlValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, x := range v {
+			m[k] = yamlValue(x)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, x := range v {
+			s[i] = yamlValue(x)
+		}
+		return s
+	}
+	return v
+}
+
+func (c Codec) encode(comp Component, parent string) (object, error) {
+	path := parent + comp.label()
+	if folder, ok := comp.(*Folder); ok {
+		children := []object{}
+		for _, child := range folder.children {
+			obj, err := c.encode(child, path+"/")
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, obj)
+		}
+		return object{{"type", "folder"}, {"name", folder.Name}, {"children", children}}, nil
+	}
+
+	name, ok := c.types().byType[reflect.TypeOf(comp)]
+	if !ok {
+		return nil, &UnknownTypeError{Path: path, Type: fmt.Sprintf("%T", comp)}
+	}
+	obj := object{{"type", name}}
+	// Let the leaf's json tags decide its fields, then copy them over in order.
+	raw, err := json.Marshal(comp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil { // {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for dec.More() {
+		key, _ := dec.Token()
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if key != "type" {
+			obj = append(obj, field{key.(string), value})
+		}
+	}
+	return obj, nil
+}
+
+func (c Codec) decode(v any, parent string, depth int) (Component, error) {
+	maxDepth := c.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 64
+	}
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%s: deeper than %d: %w", where(parent), maxDepth, ErrTooDeep)
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected an object, got %T", where(parent), v)
+	}
+	typ, ok := m["type"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: missing \"type\"", where(parent))
+	}
+	name, _ := m["name"].(string)
+	path := parent + name
+
+	if typ == "folder" {
+		if err := checkName(path, name); err != nil {
+			return nil, err
+		}
+		folder := &Folder{Name: name}
+		children, ok := m["children"].([]any)
+		if !ok && m["children"] != nil {
+			return nil, fmt.Errorf("%s: \"children\" must be a list", path)
+		}
+		for _, cv := range children {
+			child, err := c.decode(cv, path+"/", depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if err := folder.Add(child); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return folder, nil
+	}
+
+	newLeaf, ok := c.types().byName[typ]
+	if !ok {
+		return nil, &UnknownTypeError{Path: path, Type: typ}
+	}
+	// Round-trip the fields through encoding/json so the leaf's json tags apply.
+	fields := make(map[string]any, len(m))
+	for k, fv := range m {
+		if k != "type" {
+			fields[k] = fv
+		}
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	leaf := newLeaf()
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(leaf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := checkName(path, leaf.label()); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+// where names the folder an error happened in, for messages.
+func where(parent string) string {
+	if parent == "" {
+		return "top of tree"
+	}
+	return strings.TrimSuffix(parent, "/")
+}
+
+// checkName makes sure Find can reach the node: a name can't be empty or contain a slash.
+func checkName(path, name string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("%s: bad name %q", path, name)
+	}
+	return nil
+}
+
+// -- Files --
+
+// fileJSON is how a File is stored; its content isn't an exported field.
+type fileJSON struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+func (f *File) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fileJSON{Name: f.Name, Content: f.content})
+}
+
+func (f *File) UnmarshalJSON(data []byte) error {
+	var v fileJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields() // a custom Unmarshaler doesn't inherit it from Codec.decode
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	f.Name, f.content = v.Name, v.Content
+	return nil
+}
+
+// -- A Custom Leaf --
+
+// Link is a shortcut to another path. It isn't a file, so it adds nothing to Size or Count,
+// but searching finds it by where it points.
+type Link struct {
+	node
+	Name   string `json:"name"`
+	Target string `json:"target"`
+}
+
+func (l *Link) Search(query *Query) []Match {
+	if !query.MatchLine(l.Target) {
+		return nil
+	}
+	return []Match{{Path: l.Name, Line: 1, Snippet: snippet("-> " + l.Target)}}
+}
+
+func (l *Link) Size() int     { return 0 }
+func (l *Link) Count() int    { return 0 }
+func (l *Link) label() string { return l.Name }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// note is a leaf with number fields, which JSON and YAML write differently.
+type note struct {
+	node
+	Name   string  `json:"name"`
+	Pages  int     `json:"pages"`
+	Rating float64 `json:"rating,omitempty"`
+}
+
+func (n *note) Search(query *Query) []Match         { return nil }
+func (n *note) Size() int                           { return 0 }
+func (n *note) Count() int                          { return 0 }
+func (n *note) Accept(v Visitor, path string) error { return v.VisitLeaf(path, n) }
+func (n *note) label() string                       { return n.Name }
+
+func testCodec() Codec {
+	types := NewTypeRegistry()
+	RegisterType[Link](types, "link")
+	RegisterType[note](types, "note")
+	return Codec{Types: types, MaxDepth: 8}
+}
+
+func TestNumbersSurviveYAML(t *testing.T) {
+	c := testCodec()
+	root := &Folder{Name: "Root"}
+	root.Add(&note{Name: "todo", Pages: 42, Rating: 4.5})
+	root.Add(&note{Name: "big", Pages: 1 << 62})
+
+	for name, roundTrip := range map[string]func(Component) (Component, error){
+		"json": func(c0 Component) (Component, error) {
+			data, err := c.EncodeJSON(c0)
+			if err != nil {
+				return nil, err
+			}
+			return c.DecodeJSON(data)
+		},
+		"yaml": func(c0 Component) (Component, error) {
+			data, err := c.EncodeYAML(c0)
+			if err != nil {
+				return nil, err
+			}
+			return c.DecodeYAML(data)
+		},
+	} {
+		back, err := roundTrip(root)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		todo, _ := back.(*Folder).Find("Root/todo")
+		big, _ := back.(*Folder).Find("Root/big")
+		if n, ok := todo.(*note); !ok || n.Pages != 42 || n.Rating != 4.5 {
+			t.Errorf("%s: todo came back as %+v", name, todo)
+		}
+		if n, ok := big.(*note); !ok || n.Pages != 1<<62 {
+			t.Errorf("%s: big came back as %+v", name, big)
+		}
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	c := testCodec()
+	deep := strings.Repeat(`{"type":"folder","name":"d","children":[`, 9) + strings.Repeat("]}", 9)
+	var unknown *UnknownTypeError
+	for _, tt := range []struct {
+		name, in string
+		check    func(error) bool
+	}{
+		{"too deep", deep, func(err error) bool { return errors.Is(err, ErrTooDeep) }},
+		{"unknown type", `{"type":"socket","name":"s"}`, func(err error) bool { return errors.As(err, &unknown) && unknown.Type == "socket" }},
+		{"no type", `{"name":"x"}`, nil},
+		{"slash in name", `{"type":"file","name":"a/b"}`, nil},
+		{"unknown field", `{"type":"file","name":"a","color":"red"}`, nil},
+		{"two files, one name", `{"type":"folder","name":"r","children":[{"type":"file","name":"a"},{"type":"file","name":"a"}]}`, func(err error) bool { return errors.Is(err, ErrNameTaken) }},
+		{"trailing data", `{"type":"file","name":"a"} {}`, nil},
+	} {
+		_, err := c.DecodeJSON([]byte(tt.in))
+		if err == nil || (tt.check != nil && !tt.check(err)) {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+var codecSeeds = []string{
+	`{"type":"folder","name":"Root","children":[{"type":"file","name":"a.txt","content":"roses\nare red"},{"type":"folder","name":"Empty","children":[]}]}`,
+	`{"type":"folder","name":"Root","children":[{"type":"link","name":"l","target":"Root/a.txt"},{"type":"note","name":"n","pages":7,"rating":0.5}]}`,
+	`{"type":"note","name":"n","pages":9007199254740993}`,
+	`{"type":"file","name":"yes","content":"null"}`,
+}
+
+// checkRoundTrips decodes nothing itself: given a tree that decoded fine, writing it out
+// as JSON and as YAML must read back into the same tree.
+func checkRoundTrips(t *testing.T, c Codec, tree Component) {
+	t.Helper()
+	want, err := c.EncodeJSON(tree)
+	if err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	back, err := c.DecodeJSON(want)
+	if err != nil {
+		t.Fatalf("DecodeJSON of our own output: %v\n%s", err, want)
+	}
+	if got, _ := c.EncodeJSON(back); !bytes.Equal(got, want) {
+		t.Fatalf("JSON round trip changed the tree:\n%s\nbecame\n%s", want, got)
+	}
+
+	y, err := c.EncodeYAML(tree)
+	if err != nil {
+		t.Fatalf("EncodeYAML: %v", err)
+	}
+	back, err = c.DecodeYAML(y)
+	if err != nil {
+		t.Fatalf("DecodeYAML of our own output: %v\n%s", err, y)
+	}
+	if got, _ := c.EncodeJSON(back); !bytes.Equal(got, want) {
+		t.Fatalf("YAML round trip changed the tree:\n%s\nbecame\n%s", want, got)
+	}
+}
+
+func FuzzDecodeJSON(f *testing.F) {
+	for _, s := range codecSeeds {
+		f.Add([]byte(s))
+	}
+	c := testCodec()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tree, err := c.DecodeJSON(data)
+		if err != nil {
+			return
+		}
+		checkRoundTrips(t, c, tree)
+	})
+}
+
+func FuzzDecodeYAML(f *testing.F) {
+	c := testCodec()
+	for _, s := range codecSeeds {
+		tree, err := c.DecodeJSON([]byte(s))
+		if err != nil {
+			f.Fatal(err)
+		}
+		y, _ := c.EncodeYAML(tree)
+		f.Add(y)
+	}
+	f.Add([]byte("type: folder\nname: Root\nchildren:\n  - {type: note, name: n, pages: 3}\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tree, err := c.DecodeYAML(data)
+		if err != nil {
+			return
+		}
+		checkRoundTrips(t, c, tree)
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Loading a Tree from a File System
+//
+// Any io/fs.FS (a real directory with os.DirFS, files baked into the binary with embed.FS,
+// or an in-memory fstest.MapFS) becomes a Folder/File tree, so everything that works on
+// Components (like Search) works on real files too.
+
+// LoadOptions filters what LoadTree picks up. The zero value loads everything.
+type LoadOptions struct {
+	// Include keeps only files matching at least one glob, e.g. "*.txt".
+	// Folders are always walked. Empty means every file.
+	Include []string
+	// Exclude skips files and folders matching any glob, e.g. "*.tmp" or "vendor".
+	Exclude []string
+	// MaxDepth limits how many levels below root are loaded: 1 loads only root's own files,
+	// 2 adds the folders inside root and their files, and so on. 0 means no limit.
+	MaxDepth int
+}
+
+// LoadTree reads root and everything below it from fsys.
+// Globs use path.Match syntax and are tried against both the entry's name and its path
+// relative to root, so "*.go" and "docs/*.md" both work.
+func LoadTree(fsys fs.FS, root string, opts LoadOptions) (*Folder, error) {
+	for _, pattern := range append(append([]string(nil), opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad glob %q: %w", pattern, err)
+		}
+	}
+	l := &loader{fsys: fsys, root: root, opts: opts}
+	return l.loadFolder(root, path.Base(root), 0)
+}
+
+type loader struct {
+	fsys fs.FS
+	root string
+	opts LoadOptions
+}
+
+func (l *loader) loadFolder(dir, name string, depth int) (*Folder, error) {
+	folder := &Folder{Name: name}
+	entries, err := fs.ReadDir(l.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		full := path.Join(dir, e.Name())
+		rel := relativeTo(l.root, full)
+		if matchAny(l.opts.Exclude, e.Name(), rel) {
+			continue
+		}
+
+		switch {
+		case e.IsDir():
+			if l.opts.MaxDepth > 0 && depth+1 >= l.opts.MaxDepth {
+				continue
+			}
+			child, err := l.loadFolder(full, e.Name(), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if err := folder.Add(child); err != nil {
+				return nil, err
+			}
+		case e.Type().IsRegular():
+			if len(l.opts.Include) > 0 && !matchAny(l.opts.Include, e.Name(), rel) {
+				continue
+			}
+			content, err := fs.ReadFile(l.fsys, full)
+			if err != nil {
+				return nil, err
+			}
+			if err := folder.Add(NewFile(e.Name(), string(content))); err != nil {
+				return nil, err
+			}
+		}
+		// Symlinks, devices and the like are skipped.
+	}
+	return folder, nil
+}
+
+func relativeTo(root, p string) string {
+	if root == "." {
+		return p
+	}
+	return p[len(root)+1:]
+}
+
+// matchAny reports whether name or rel matches any of the globs.
+// The patterns were checked in LoadTree, so errors can't happen here.
+func matchAny(patterns []string, name, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
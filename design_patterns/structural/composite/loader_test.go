@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var garden = fstest.MapFS{
+	"garden/README.md":               {Data: []byte("# The Garden\nEvery rose has its thorn.")},
+	"garden/beds/roses.txt":          {Data: []byte("Red rose\nWhite rose")},
+	"garden/beds/notes.tmp":          {Data: []byte("rose draft, please ignore")},
+	"garden/beds/deep/seeds/log.txt": {Data: []byte("rose seeds planted")},
+	"garden/shed/tools.txt":          {Data: []byte("rake, hose, shovel")},
+	"garden/shed/link.txt":           {Data: []byte("tools.txt"), Mode: fs.ModeSymlink},
+}
+
+// paths lists every file in the tree, and every folder with a trailing slash.
+func paths(root Component) []string {
+	var out []string
+	Walk(root, func(path string, c Component) error {
+		if _, ok := c.(*Folder); ok {
+			path += "/"
+		}
+		out = append(out, path)
+		return nil
+	}, nil)
+	return out
+}
+
+func TestLoadTree(t *testing.T) {
+	tests := []struct {
+		name string
+		opts LoadOptions
+		want []string
+	}{
+		{"everything", LoadOptions{}, []string{
+			"garden/", "garden/README.md",
+			"garden/beds/", "garden/beds/deep/", "garden/beds/deep/seeds/", "garden/beds/deep/seeds/log.txt",
+			"garden/beds/notes.tmp", "garden/beds/roses.txt",
+			"garden/shed/", "garden/shed/tools.txt",
+		}},
+		{"include by name", LoadOptions{Include: []string{"*.md"}}, []string{
+			"garden/", "garden/README.md",
+			"garden/beds/", "garden/beds/deep/", "garden/beds/deep/seeds/", "garden/shed/",
+		}},
+		{"include by path", LoadOptions{Include: []string{"beds/*.txt"}}, []string{
+			"garden/",
+			"garden/beds/", "garden/beds/deep/", "garden/beds/deep/seeds/", "garden/beds/roses.txt",
+			"garden/shed/",
+		}},
+		{"exclude files and folders", LoadOptions{Exclude: []string{"*.tmp", "deep", "shed"}}, []string{
+			"garden/", "garden/README.md", "garden/beds/", "garden/beds/roses.txt",
+		}},
+		{"depth 1", LoadOptions{MaxDepth: 1}, []string{
+			"garden/", "garden/README.md",
+		}},
+		{"depth 2", LoadOptions{MaxDepth: 2, Include: []string{"*.txt", "*.md"}}, []string{
+			"garden/", "garden/README.md",
+			"garden/beds/", "garden/beds/roses.txt",
+			"garden/shed/", "garden/shed/tools.txt",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := LoadTree(garden, "garden", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := paths(tree); !slices.Equal(got, tt.want) {
+				t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(tt.want, "\n  "))
+			}
+		})
+	}
+}
+
+func TestLoadTreeContentAndSearch(t *testing.T) {
+	tree, err := LoadTree(garden, "garden", LoadOptions{Include: []string{"*.txt", "*.md"}, Exclude: []string{"shed"}, MaxDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Count() != 2 || tree.Size() != len("# The Garden\nEvery rose has its thorn.")+len("Red rose\nWhite rose") {
+		t.Errorf("%d files, %d bytes", tree.Count(), tree.Size())
+	}
+	query, _ := NewQuery("rose")
+	var got []string
+	for _, m := range tree.Search(query) {
+		got = append(got, m.Path)
+	}
+	want := []string{"garden/README.md", "garden/beds/roses.txt", "garden/beds/roses.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("matches in %v, want %v", got, want)
+	}
+}
+
+func TestLoadTreeFromDot(t *testing.T) {
+	sub, _ := fs.Sub(garden, "garden/beds")
+	tree, err := LoadTree(sub, ".", LoadOptions{Exclude: []string{"deep/seeds"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"./", "./deep/", "./notes.tmp", "./roses.txt"}
+	if got := paths(tree); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLoadTreeErrors(t *testing.T) {
+	if _, err := LoadTree(garden, "garden", LoadOptions{Include: []string{"["}}); err == nil {
+		t.Error("a bad glob was accepted")
+	}
+	if _, err := LoadTree(garden, "orchard", LoadOptions{}); err == nil {
+		t.Error("loaded a folder that doesn't exist")
+	}
+}
@@ -1,6 +1,13 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
 
 // Composite Pattern
 //
@@ -17,41 +24,94 @@ import "fmt"
 // The "Group" COMPOSES multiple shapes but treats them as one unit.
 
 type Component interface {
-	Search(keyword string)
+	Search(query *Query) []Match
+	Size() int  // bytes of content, in total
+	Count() int // files, in total
+	Parent() *Folder
+	Accept(v Visitor, path string) error // see visit.go
+
+	label() string
+	setParent(f *Folder)
+}
+
+// node is the part every Component shares: a link back to the folder holding it.
+type node struct {
+	parent *Folder
 }
 
+// Parent is the folder holding this component, or nil for the top of a tree.
+func (n *node) Parent() *Folder     { return n.parent }
+func (n *node) setParent(f *Folder) { n.parent = f }
+
 // File is a simple leaf node (Start, End)
 type File struct {
-	Name string
+	node
+	Name    string
+	content string // only changed through SetContent, so folders above hear about it
+}
+
+func NewFile(name, content string) *File {
+	return &File{Name: name, content: content}
 }
 
-func (f *File) Search(keyword string) {
-	fmt.Printf("Searching for keyword %s in file %s\n", keyword, f.Name)
+func (f *File) Content() string { return f.content }
+
+// Search checks every line of the file.
+func (f *File) Search(query *Query) []Match {
+	var matches []Match
+	for i, line := range strings.Split(f.content, "\n") {
+		if query.MatchLine(line) {
+			matches = append(matches, Match{Path: f.Name, Line: i + 1, Snippet: snippet(line)})
+		}
+	}
+	return matches
+}
+
+func (f *File) Size() int     { return len(f.content) }
+func (f *File) Count() int    { return 1 }
+func (f *File) label() string { return f.Name }
+
+// SetContent replaces the content and lets every folder above know their totals are stale.
+func (f *File) SetContent(content string) {
+	f.content = content
+	if f.parent != nil {
+		f.parent.invalidate()
+	}
 }
 
 // Folder is a composite that can hold Files or other Folders
 type Folder struct {
-	Components []Component
-	Name       string
+	node
+	Name     string
+	children []Component
+
+	// Cached totals, see tree.go.
+	mu     sync.Mutex
+	cached bool
+	size   int
+	count  int
 }
 
-func (f *Folder) Search(keyword string) {
-	fmt.Printf("Searching recursively for keyword %s in folder %s\n", keyword, f.Name)
-	for _, composite := range f.Components {
-		composite.Search(keyword)
+// Search asks every child to search, and puts the folder's name in front of their paths.
+func (f *Folder) Search(query *Query) []Match {
+	var matches []Match
+	for _, composite := range f.children {
+		for _, m := range composite.Search(query) {
+			m.Path = f.Name + "/" + m.Path
+			matches = append(matches, m)
+		}
 	}
+	return matches
 }
 
-func (f *Folder) Add(c Component) {
-	f.Components = append(f.Components, c)
-}
+func (f *Folder) label() string { return f.Name }
 
 func main() {
 	fmt.Println("--- Composite Pattern: Boxes inside Boxes ---")
 
-	file1 := &File{Name: "File1"}
-	file2 := &File{Name: "File2"}
-	file3 := &File{Name: "File3"}
+	file1 := NewFile("File1", "Roses are red,\nviolets are blue.")
+	file2 := NewFile("File2", "A rose by any other name\nwould smell as sweet.")
+	file3 := NewFile("File3", "Tulips and daisies only.")
 
 	folder1 := &Folder{Name: "Folder1"}
 	folder1.Add(file1)
@@ -61,7 +121,129 @@ func main() {
 	folder2.Add(file3)
 	folder2.Add(folder1) // Adding a folder inside a folder!
 
-	fmt.Println("\nSearching in the top-level folder (Folder2):")
-	// One call to search triggers search in ALL sub-folders and files!
-	folder2.Search("rose")
+	search := func(keyword string, opts ...QueryOption) {
+		query, err := NewQuery(keyword, opts...)
+		if err != nil {
+			fmt.Println("Bad query:", err)
+			return
+		}
+		// One call to search triggers search in ALL sub-folders and files!
+		matches := folder2.Search(query)
+		fmt.Printf("\nSearching Folder2 for %q: %d match(es)\n", keyword, len(matches))
+		for _, m := range matches {
+			fmt.Printf("  %s:%d: %s\n", m.Path, m.Line, m.Snippet)
+		}
+	}
+
+	search("rose")
+	search("rose", IgnoreCase())
+	search(`(violets|daisies)`, AsRegex())
+	search("rose[", AsRegex()) // not a valid regular expression
+
+	// Many helpers can search at once, and the answer still comes out in the same order.
+	fmt.Println("\n--- Composite Pattern: Searching in Parallel ---")
+	query, _ := NewQuery("rose", IgnoreCase())
+	all, _ := ParallelSearch(context.Background(), folder2, query, SearchOptions{Workers: 4})
+	fmt.Printf("All matches: %d, same as Folder2.Search: %v\n", len(all), slices.Equal(all, folder2.Search(query)))
+	first, _ := ParallelSearch(context.Background(), folder2, query, SearchOptions{Workers: 4, Limit: 1})
+	fmt.Printf("First match only: %s:%d\n", first[0].Path, first[0].Line)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ParallelSearch(cancelled, folder2, query, SearchOptions{}); err != nil {
+		fmt.Println("Cancelled search:", err)
+	}
+
+	// Every box knows which box it's in, so the tree can't be tied in a knot.
+	fmt.Println("\n--- Composite Pattern: Changing the Tree ---")
+	fmt.Printf("Folder2 holds %d file(s), %d bytes\n", folder2.Count(), folder2.Size())
+	if err := folder1.Add(folder2); err != nil {
+		fmt.Println("Refused:", err)
+	}
+	if err := folder1.Add(folder1); err != nil {
+		fmt.Println("Refused:", err)
+	}
+	if err := folder2.Add(file1); err != nil {
+		fmt.Println("Refused:", err)
+	}
+	if c, ok := folder2.Find("Folder2/Folder1/File1"); ok {
+		fmt.Println("Found Folder2/Folder1/File1 inside", c.Parent().Name)
+	}
+
+	file1.SetContent("Roses are red.")
+	fmt.Printf("After editing File1: %d bytes\n", folder2.Size())
+	if err := folder2.Move(file3, folder1); err != nil {
+		fmt.Println("Move failed:", err)
+	}
+	_, ok := folder2.Find("Folder2/Folder1/File3")
+	fmt.Printf("After moving File3 into Folder1: found at Folder2/Folder1/File3? %v\n", ok)
+	if err := folder1.Remove(file1); err != nil {
+		fmt.Println("Remove failed:", err)
+	}
+	fmt.Printf("After removing File1: %d file(s), %d bytes\n", folder2.Count(), folder2.Size())
+
+	// Save the tree and load it back. The "type" tag says which kind of box or toy each one is.
+	fmt.Println("\n--- Composite Pattern: Saving and Loading ---")
+	types := NewTypeRegistry()
+	RegisterType[Link](types, "link")
+	codec := Codec{Types: types, MaxDepth: 8}
+	folder2.Add(&Link{Name: "Poem", Target: "Folder2/File2"})
+
+	data, err := codec.EncodeJSON(folder2)
+	if err != nil {
+		fmt.Println("Encode failed:", err)
+		return
+	}
+	fmt.Printf("JSON:\n%s\n", data)
+	restored, err := codec.DecodeJSON(data)
+	if err != nil {
+		fmt.Println("Decode failed:", err)
+		return
+	}
+	fmt.Printf("Restored: %d file(s), %d bytes, same search results: %v\n",
+		restored.Count(), restored.Size(), slices.Equal(restored.Search(query), folder2.Search(query)))
+
+	yamlData, _ := codec.EncodeYAML(restored)
+	fmt.Printf("YAML:\n%s", yamlData)
+	if again, err := codec.DecodeYAML(yamlData); err == nil {
+		fmt.Printf("Restored from YAML: %d file(s), %d bytes\n", again.Count(), again.Size())
+	}
+
+	if _, err := codec.DecodeJSON([]byte(`{"type":"folder","name":"A","children":[{"type":"widget","name":"W"}]}`)); err != nil {
+		fmt.Println("Refused:", err)
+	}
+	deep := `{"type":"file","name":"F"}`
+	for i := 0; i < 10; i++ {
+		deep = `{"type":"folder","name":"D","children":[` + deep + `]}`
+	}
+	if _, err := codec.DecodeJSON([]byte(deep)); err != nil {
+		fmt.Println("Refused:", err)
+	}
+
+	// New operations without touching File, Folder or Link: each one is a Visitor.
+	fmt.Println("\n--- Composite Pattern: Visitors and Walk ---")
+	var sizes SizeVisitor
+	Visit(restored, &sizes)
+	fmt.Printf("Sizes: %d file(s), %d folder(s), %d link(s), %d bytes\n", sizes.Files, sizes.Folders, sizes.Links, sizes.Bytes)
+	Visit(restored, &TreePrinter{W: os.Stdout})
+	exporter := NewCSVExporter(os.Stdout)
+	Visit(restored, exporter)
+	exporter.Flush()
+	finder := &SearchVisitor{Query: query}
+	Visit(restored, finder)
+	fmt.Printf("SearchVisitor agrees with Search: %v\n", slices.Equal(finder.Matches, restored.Search(query)))
+
+	// Walk: pre on the way down, post on the way up. Skip Folder1 entirely.
+	Walk(restored,
+		func(path string, c Component) error {
+			if folder, ok := c.(*Folder); ok && folder.Name == "Folder1" {
+				fmt.Println("  skip", path)
+				return SkipSubtree
+			}
+			fmt.Println("  enter", path)
+			return nil
+		},
+		func(path string, c Component) error {
+			fmt.Println("  leave", path)
+			return nil
+		})
 }
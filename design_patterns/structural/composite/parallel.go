@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Parallel Search
+//
+// Folder.Search looks in one file at a time. For a big tree we first walk it once to list
+// every file (cheap: no content is read), then let a fixed number of helpers search the
+// files. Each file's results go into its own slot, so the answer comes out in the same
+// order as Folder.Search no matter which helper finishes first.
+//
+// To compare it with Folder.Search on a generated tree of 100,000 nodes:
+//
+//	go test -bench Search ./design_patterns/structural/composite
+
+// SearchOptions tunes ParallelSearch. The zero value uses one worker per CPU and no limit.
+type SearchOptions struct {
+	Workers int // goroutines searching at once; default runtime.GOMAXPROCS(0)
+	Limit   int // stop after this many matches (the first ones, in tree order); 0 means all
+}
+
+// leaf is one file to search, with the path of the folders above it.
+type leaf struct {
+	prefix string // e.g. "Folder2/Folder1/"
+	c      Component
+}
+
+func (l leaf) search(query *Query) []Match {
+	matches := l.c.Search(query)
+	for i := range matches {
+		matches[i].Path = l.prefix + matches[i].Path
+	}
+	return matches
+}
+
+func collectLeaves(c Component, prefix string, leaves *[]leaf) {
+	folder, ok := c.(*Folder)
+	if !ok {
+		*leaves = append(*leaves, leaf{prefix: prefix, c: c})
+		return
+	}
+	prefix += folder.Name + "/"
+	for _, child := range folder.children {
+		collectLeaves(child, prefix, leaves)
+	}
+}
+
+// ParallelSearch returns the same matches as root.Search(query), in the same order,
+// searching up to opts.Workers files at once. If ctx is cancelled first it returns ctx's error.
+func ParallelSearch(ctx context.Context, root Component, query *Query, opts SearchOptions) ([]Match, error) {
+	var leaves []leaf
+	collectLeaves(root, "", &leaves)
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	work, stop := context.WithCancel(ctx)
+	defer stop()
+
+	results := make([][]Match, len(leaves))
+	var next atomic.Int64 // files are handed out in tree order
+
+	// With a limit, we can stop once every file up to some point is done and together they
+	// hold enough matches. Later files can't change the first Limit matches.
+	var (
+		mu       sync.Mutex
+		done     = make([]bool, len(leaves))
+		frontier int // files [0, frontier) are all done
+		found    int // matches in those files
+	)
+	finished := func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		done[i] = true
+		for frontier < len(leaves) && done[frontier] {
+			found += len(results[frontier])
+			frontier++
+		}
+		if found >= opts.Limit {
+			stop()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range min(workers, len(leaves)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for work.Err() == nil {
+				i := int(next.Add(1) - 1)
+				if i >= len(leaves) {
+					return
+				}
+				results[i] = leaves[i].search(query)
+				if opts.Limit > 0 {
+					finished(i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var matches []Match
+	for _, r := range results {
+		matches = append(matches, r...)
+		if opts.Limit > 0 && len(matches) >= opts.Limit {
+			return matches[:opts.Limit], nil
+		}
+	}
+	return matches, nil
+}
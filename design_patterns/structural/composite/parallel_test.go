@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// generateTree builds a tree of about n Components: every folder holds 8 files and 2
+// subfolders, filled breadth-first. Every 7th file mentions a needle.
+func generateTree(n int) *Folder {
+	root := &Folder{Name: "root"}
+	queue := []*Folder{root}
+	for made := 1; made < n; {
+		f := queue[0]
+		queue = queue[1:]
+		for i := 0; i < 10 && made < n; i++ {
+			made++
+			if i >= 8 {
+				sub := &Folder{Name: fmt.Sprintf("dir%d", made)}
+				f.Add(sub)
+				queue = append(queue, sub)
+				continue
+			}
+			var content strings.Builder
+			for line := 0; line < 20; line++ {
+				if line == 10 && made%7 == 0 {
+					content.WriteString("here is the needle\n")
+				} else {
+					fmt.Fprintf(&content, "line %d of some ordinary hay\n", line)
+				}
+			}
+			f.Add(NewFile(fmt.Sprintf("file%d.txt", made), content.String()))
+		}
+	}
+	return root
+}
+
+func TestParallelSearchKeepsTreeOrder(t *testing.T) {
+	root := generateTree(2000)
+	query, _ := NewQuery("needle")
+	want := root.Search(query)
+	if len(want) == 0 {
+		t.Fatal("the generated tree has no needles")
+	}
+	for _, workers := range []int{0, 1, 2, 4, 8, 1000} {
+		got, err := ParallelSearch(context.Background(), root, query, SearchOptions{Workers: workers})
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("%d workers: %d matches, %v; want the %d of Folder.Search in the same order", workers, len(got), err, len(want))
+		}
+	}
+}
+
+func TestParallelSearchSmallTrees(t *testing.T) {
+	query, _ := NewQuery("rose", IgnoreCase())
+	file := NewFile("File1", "Roses are red")
+	for name, root := range map[string]Component{
+		"empty folder": &Folder{Name: "Empty"},
+		"lone file":    file,
+	} {
+		got, err := ParallelSearch(context.Background(), root, query, SearchOptions{})
+		if want := root.Search(query); err != nil || !slices.Equal(got, want) {
+			t.Errorf("%s: got %v, %v; want %v", name, got, err, want)
+		}
+	}
+}
+
+func TestParallelSearchLimit(t *testing.T) {
+	root := generateTree(2000)
+	query, _ := NewQuery("needle")
+	want := root.Search(query)
+	for _, limit := range []int{1, 10, len(want), len(want) + 1} {
+		for _, workers := range []int{1, 4} {
+			got, err := ParallelSearch(context.Background(), root, query, SearchOptions{Workers: workers, Limit: limit})
+			if err != nil || !slices.Equal(got, want[:min(limit, len(want))]) {
+				t.Errorf("limit %d, %d workers: got %d matches, %v; want the first %d", limit, workers, len(got), err, min(limit, len(want)))
+			}
+		}
+	}
+}
+
+// cancellingFile cancels the search as soon as it is searched itself.
+type cancellingFile struct {
+	*File
+	cancel context.CancelFunc
+}
+
+func (f cancellingFile) Search(query *Query) []Match {
+	f.cancel()
+	return f.File.Search(query)
+}
+
+func TestParallelSearchCancelled(t *testing.T) {
+	query, _ := NewQuery("needle")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got, err := ParallelSearch(ctx, generateTree(100), query, SearchOptions{}); got != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled before starting: got %d matches, %v", len(got), err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	root := generateTree(100)
+	root.Add(cancellingFile{File: NewFile("tripwire", "needle"), cancel: cancel})
+	if got, err := ParallelSearch(ctx, root, query, SearchOptions{Workers: 2}); got != nil || !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled halfway: got %d matches, %v", len(got), err)
+	}
+}
+
+func BenchmarkSearchSequential(b *testing.B) {
+	root := generateTree(100_000)
+	query, _ := NewQuery("needle")
+	b.ResetTimer()
+	for range b.N {
+		root.Search(query)
+	}
+}
+
+func BenchmarkParallelSearch(b *testing.B) {
+	root := generateTree(100_000)
+	query, _ := NewQuery("needle")
+	for _, opts := range []SearchOptions{{Workers: 1}, {Workers: 2}, {Workers: 4}, {Workers: 8}, {Limit: 10}} {
+		b.Run(fmt.Sprintf("workers=%d/limit=%d", opts.Workers, opts.Limit), func(b *testing.B) {
+			for range b.N {
+				if _, err := ParallelSearch(context.Background(), root, query, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Search Queries
+//
+// A Query is compiled once and then handed down the whole tree, so every File
+// checks its lines the same way, whether we search one file or a thousand folders.
+
+// Match is one line that matched a query.
+type Match struct {
+	Path    string // e.g. "Folder2/Folder1/File1"
+	Line    int    // 1-based
+	Snippet string // the matching line, trimmed
+}
+
+// Query is a compiled search.
+type Query struct {
+	keyword    string
+	ignoreCase bool
+	regex      bool
+	re         *regexp.Regexp
+}
+
+// QueryOption changes how a Query matches.
+type QueryOption func(*Query)
+
+// IgnoreCase makes "Rose" find "rose" and "ROSE".
+func IgnoreCase() QueryOption {
+	return func(q *Query) {
+		q.ignoreCase = true
+	}
+}
+
+// AsRegex treats the keyword as a regular expression.
+func AsRegex() QueryOption {
+	return func(q *Query) {
+		q.regex = true
+	}
+}
+
+// NewQuery compiles a query. It only fails for an invalid regular expression.
+func NewQuery(keyword string, opts ...QueryOption) (*Query, error) {
+	q := &Query{keyword: keyword}
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	if q.regex {
+		pattern := keyword
+		if q.ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		q.re = re
+	} else if q.ignoreCase {
+		q.keyword = strings.ToLower(keyword)
+	}
+	return q, nil
+}
+
+// MatchLine reports whether one line of text matches.
+func (q *Query) MatchLine(line string) bool {
+	switch {
+	case q.re != nil:
+		return q.re.MatchString(line)
+	case q.ignoreCase:
+		return strings.Contains(strings.ToLower(line), q.keyword)
+	default:
+		return strings.Contains(line, q.keyword)
+	}
+}
+
+// maxSnippet keeps very long lines readable in results.
+const maxSnippet = 80
+
+func snippet(line string) string {
+	line = strings.TrimSpace(line)
+	if r := []rune(line); len(r) > maxSnippet {
+		return string(r[:maxSnippet-1]) + "…"
+	}
+	return line
+}
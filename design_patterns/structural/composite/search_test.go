@@ -0,0 +1,63 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	long := "rose " + strings.Repeat("é", 100)
+	content := "Roses are red\n  violets are blue  \nROSE\n\n" + long
+	tests := []struct {
+		name    string
+		keyword string
+		opts    []QueryOption
+		want    []Match
+	}{
+		{"plain", "Rose", nil, []Match{
+			{"poem.txt", 1, "Roses are red"},
+		}},
+		{"ignore case", "rose", []QueryOption{IgnoreCase()}, []Match{
+			{"poem.txt", 1, "Roses are red"},
+			{"poem.txt", 3, "ROSE"},
+			{"poem.txt", 5, "rose " + strings.Repeat("é", maxSnippet-len("rose ")-1) + "…"}, // cut to maxSnippet runes
+		}},
+		{"regex", `^\s*v\w+ are`, []QueryOption{AsRegex()}, []Match{
+			{"poem.txt", 2, "violets are blue"},
+		}},
+		{"regex ignoring case", `^rose$`, []QueryOption{AsRegex(), IgnoreCase()}, []Match{
+			{"poem.txt", 3, "ROSE"},
+		}},
+		{"regex is case sensitive", `^rose$`, []QueryOption{AsRegex()}, nil},
+		{"not a regex without AsRegex", `^ROSE$`, nil, nil},
+	}
+	f := NewFile("poem.txt", content)
+	for _, tt := range tests {
+		q, err := NewQuery(tt.keyword, tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := f.Search(q); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := NewQuery("(rose", AsRegex()); err == nil {
+		t.Error("compiled an unbalanced regex")
+	}
+	if _, err := NewQuery("(rose"); err != nil {
+		t.Errorf("a plain keyword isn't a pattern: %v", err)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("é", maxSnippet+20)
+	got := snippet("  " + long + "  ")
+	if r := []rune(got); len(r) != maxSnippet || !strings.HasPrefix(long, string(r[:maxSnippet-1])) || r[maxSnippet-1] != '…' {
+		t.Errorf("got %q (%d runes), want the first %d runes and an ellipsis", got, len(r), maxSnippet-1)
+	}
+	if got := snippet(strings.Repeat("a", maxSnippet)); got != strings.Repeat("a", maxSnippet) {
+		t.Errorf("a line of exactly %d runes was cut: %q", maxSnippet, got)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Changing the Tree Safely
+//
+// Every Component knows its parent, and a Component lives in exactly one Folder.
+// That rule is what keeps the tree a tree: a box can't be put inside itself, or inside
+// a box that is already inside it, so Search (and everything else) always finishes.
+//
+// Size and Count are remembered by each Folder. Any change below a folder (Add, Remove,
+// Move, File.SetContent) forgets the remembered totals of that folder and every folder above it.
+// Many goroutines may read a tree at once; changing it while anyone reads it is not safe.
+
+var (
+	ErrCycle     = errors.New("a folder can't go inside itself or its own subfolders")
+	ErrHasParent = errors.New("already in a folder; use Move")
+	ErrNameTaken = errors.New("name already used in this folder")
+	ErrNotChild  = errors.New("not in this folder")
+	ErrNoFolder  = errors.New("no folder to move to; use Remove")
+)
+
+// Add puts c inside f.
+func (f *Folder) Add(c Component) error {
+	if err := f.canAdd(c); err != nil {
+		return err
+	}
+	if c.Parent() != nil {
+		return fmt.Errorf("add %q to %q: %w", c.label(), f.Name, ErrHasParent)
+	}
+	f.attach(c)
+	return nil
+}
+
+// canAdd checks everything Add and Move have in common.
+func (f *Folder) canAdd(c Component) error {
+	if folder, ok := c.(*Folder); ok {
+		// Walking up from f is enough: if c is f or any folder above it, we'd make a loop.
+		for p := f; p != nil; p = p.parent {
+			if p == folder {
+				return fmt.Errorf("add %q to %q: %w", c.label(), f.Name, ErrCycle)
+			}
+		}
+	}
+	for _, child := range f.children {
+		if child.label() == c.label() {
+			return fmt.Errorf("add %q to %q: %w", c.label(), f.Name, ErrNameTaken)
+		}
+	}
+	return nil
+}
+
+func (f *Folder) attach(c Component) {
+	f.children = append(f.children, c)
+	c.setParent(f)
+	f.invalidate()
+}
+
+// Remove takes c out of f. c becomes the top of its own tree.
+func (f *Folder) Remove(c Component) error {
+	if c.Parent() != f {
+		return fmt.Errorf("remove %q from %q: %w", c.label(), f.Name, ErrNotChild)
+	}
+	f.detach(c)
+	return nil
+}
+
+func (f *Folder) detach(c Component) {
+	for i, child := range f.children {
+		if child == c {
+			f.children = append(f.children[:i], f.children[i+1:]...)
+			break
+		}
+	}
+	c.setParent(nil)
+	f.invalidate()
+}
+
+// Move takes c out of f and puts it inside to. Nothing changes if the move isn't allowed.
+func (f *Folder) Move(c Component, to *Folder) error {
+	if c.Parent() != f {
+		return fmt.Errorf("move %q from %q: %w", c.label(), f.Name, ErrNotChild)
+	}
+	if to == nil {
+		return fmt.Errorf("move %q from %q: %w", c.label(), f.Name, ErrNoFolder)
+	}
+	if to == f {
+		return nil
+	}
+	if err := to.canAdd(c); err != nil {
+		return err
+	}
+	f.detach(c)
+	to.attach(c)
+	return nil
+}
+
+// Children returns a copy of f's children, so changing the slice can't skip the rules above.
+func (f *Folder) Children() []Component {
+	return append([]Component(nil), f.children...)
+}
+
+// Find looks up a path like the ones in Match, starting with f's own name:
+// folder2.Find("Folder2/Folder1/File1").
+func (f *Folder) Find(path string) (Component, bool) {
+	first, rest, more := strings.Cut(path, "/")
+	if first != f.Name {
+		return nil, false
+	}
+	var current Component = f
+	for more {
+		folder, ok := current.(*Folder)
+		if !ok {
+			return nil, false // a file has nothing inside it
+		}
+		first, rest, more = strings.Cut(rest, "/")
+		current = nil
+		for _, child := range folder.children {
+			if child.label() == first {
+				current = child
+				break
+			}
+		}
+		if current == nil {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Size is the total bytes of every file below f.
+func (f *Folder) Size() int {
+	size, _ := f.total()
+	return size
+}
+
+// Count is the number of files below f.
+func (f *Folder) Count() int {
+	_, count := f.total()
+	return count
+}
+
+// total returns the cached totals, adding them up first if needed. Readers lock a folder
+// before its children, so two of them working down the same tree never wait in a circle.
+func (f *Folder) total() (size, count int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.cached {
+		f.size, f.count = 0, 0
+		for _, child := range f.children {
+			f.size += child.Size()
+			f.count += child.Count()
+		}
+		f.cached = true
+	}
+	return f.size, f.count
+}
+
+// invalidate forgets the totals of f and every folder above it.
+// A folder's totals are only cached after its children's are, so once we meet a folder
+// that isn't cached, the folders above it can't be cached either and we can stop.
+// It holds one folder's lock at a time, so it can't deadlock with total.
+func (f *Folder) invalidate() {
+	for p := f; p != nil; p = p.parent {
+		p.mu.Lock()
+		cached := p.cached
+		p.cached = false
+		p.mu.Unlock()
+		if !cached {
+			return
+		}
+	}
+}
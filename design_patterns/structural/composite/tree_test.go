@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// sampleTree is
+//
+//	Root
+//	├─ a.txt "aaaa"
+//	└─ Docs
+//	   ├─ b.txt "bb"
+//	   └─ Old
+//	      └─ c.txt "c"
+func sampleTree(t *testing.T) (root, docs, old *Folder, a, b, c *File) {
+	t.Helper()
+	root, docs, old = &Folder{Name: "Root"}, &Folder{Name: "Docs"}, &Folder{Name: "Old"}
+	a, b, c = NewFile("a.txt", "aaaa"), NewFile("b.txt", "bb"), NewFile("c.txt", "c")
+	for _, step := range []struct {
+		to *Folder
+		c  Component
+	}{{root, a}, {root, docs}, {docs, b}, {docs, old}, {old, c}} {
+		if err := step.to.Add(step.c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+func TestAddRejects(t *testing.T) {
+	root, docs, old, a, _, _ := sampleTree(t)
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"itself", docs.Add(docs), ErrCycle},
+		{"its parent", old.Add(docs), ErrCycle},
+		{"the top", old.Add(root), ErrCycle},
+		{"already elsewhere", docs.Add(a), ErrHasParent},
+		{"same name", root.Add(&File{Name: "a.txt"}), ErrNameTaken},
+		{"remove a stranger", docs.Remove(a), ErrNotChild},
+		{"move a stranger", docs.Move(a, old), ErrNotChild},
+		{"move into its own subfolder", root.Move(docs, old), ErrCycle},
+		{"move onto a name", docs.Move(old, root), nil},
+		{"move to nowhere", root.Move(a, nil), ErrNoFolder},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+	if a.Parent() != root {
+		t.Error("a refused change moved a.txt anyway")
+	}
+}
+
+func TestMoveKeepsTheTreeWhenRefused(t *testing.T) {
+	root, docs, old, _, b, _ := sampleTree(t)
+	old.Add(&File{Name: "b.txt"})
+	if err := docs.Move(b, old); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("got %v, want ErrNameTaken", err)
+	}
+	if got, ok := root.Find("Root/Docs/b.txt"); !ok || got != b {
+		t.Error("b.txt left Docs although the move was refused")
+	}
+	if err := docs.Move(b, docs); err != nil || b.Parent() != docs {
+		t.Errorf("moving into the same folder: %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root, docs, _, a, _, c := sampleTree(t)
+	tests := []struct {
+		path string
+		want Component
+	}{
+		{"Root", root},
+		{"Root/a.txt", a},
+		{"Root/Docs", docs},
+		{"Root/Docs/Old/c.txt", c},
+		{"Root/Docs/Old/d.txt", nil},
+		{"Root/a.txt/more", nil},
+		{"Docs/b.txt", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, ok := root.Find(tt.path)
+		if ok != (tt.want != nil) || (ok && got != tt.want) {
+			t.Errorf("Find(%q) = %v, %v", tt.path, got, ok)
+		}
+	}
+}
+
+func TestTotalsFollowChanges(t *testing.T) {
+	root, docs, old, a, b, c := sampleTree(t)
+	check := func(step string, folder *Folder, size, count int) {
+		t.Helper()
+		if folder.Size() != size || folder.Count() != count {
+			t.Errorf("%s: %s has %d bytes in %d files, want %d in %d", step, folder.Name, folder.Size(), folder.Count(), size, count)
+		}
+	}
+	check("start", root, 7, 3)
+	check("start", docs, 3, 2)
+	check("start", old, 1, 1)
+
+	c.SetContent("cccccc") // deep below, with every total cached
+	check("SetContent", old, 6, 1)
+	check("SetContent", root, 12, 3)
+
+	if err := docs.Move(old, root); err != nil {
+		t.Fatal(err)
+	}
+	check("Move", docs, 2, 1)
+	check("Move", old, 6, 1)
+	check("Move", root, 12, 3)
+
+	if err := root.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	check("Remove", root, 8, 2)
+	a.SetContent("no longer counted anywhere")
+	check("SetContent after Remove", root, 8, 2)
+
+	b.SetContent("")
+	check("SetContent to nothing", docs, 0, 1)
+	check("SetContent to nothing", root, 6, 2)
+}
+
+// Run with -race: the first readers fill in the cached totals while the others read them.
+func TestConcurrentReaders(t *testing.T) {
+	same := generateTree(2000)
+	want := [2]int{same.Size(), same.Count()}
+	root := generateTree(2000) // nothing cached yet
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := [2]int{root.Size(), root.Count()}; got != want {
+				t.Errorf("got %d bytes in %d files, want %d in %d", got[0], got[1], want[0], want[1])
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Walking and Visiting
+//
+// Walk goes through the whole tree in order, calling pre on the way down and post on
+// the way back up, so operations that don't care about node types (counting levels,
+// pruning a folder) don't need any new methods.
+//
+// A Visitor is for operations that DO care about the type: each node only knows how to
+// Accept one, calling the Visit method for its own type. New operations are new Visitors;
+// File, Folder and Link never change. A new kind of leaf (one registered with a
+// TypeRegistry) doesn't get a method of its own: it calls VisitLeaf, which every Visitor
+// handles through the Component methods alone. A Visitor that wants to treat it
+// specially can switch on its type there.
+
+// SkipSubtree can be returned by a pre callback (or a VisitFolder) to skip everything
+// inside that folder. Walk itself never returns it.
+var SkipSubtree = errors.New("skip this subtree")
+
+// WalkFunc is called with each component and its path, e.g. "Folder2/Folder1/File1".
+type WalkFunc func(path string, c Component) error
+
+// Walk visits root and everything below it. pre runs before a folder's children, post
+// after them; either may be nil. Any error other than SkipSubtree stops the walk.
+func Walk(root Component, pre, post WalkFunc) error {
+	if err := walk(root, root.label(), pre, post); err != SkipSubtree {
+		return err
+	}
+	return nil
+}
+
+func walk(c Component, path string, pre, post WalkFunc) error {
+	if pre != nil {
+		if err := pre(path, c); err != nil {
+			return err // SkipSubtree included: no children, no post
+		}
+	}
+	if folder, ok := c.(*Folder); ok {
+		// A copy, so callbacks may Add, Remove or Move without confusing the walk.
+		for _, child := range folder.Children() {
+			if err := walk(child, path+"/"+child.label(), pre, post); err != nil && err != SkipSubtree {
+				return err
+			}
+		}
+	}
+	if post != nil {
+		if err := post(path, c); err != nil && err != SkipSubtree {
+			return err
+		}
+	}
+	return nil
+}
+
+// Visitor is one operation over every kind of node.
+type Visitor interface {
+	VisitFile(path string, f *File) error
+	VisitFolder(path string, f *Folder) error
+	VisitLink(path string, l *Link) error
+	VisitLeaf(path string, c Component) error // any other leaf
+}
+
+func (f *File) Accept(v Visitor, path string) error   { return v.VisitFile(path, f) }
+func (f *Folder) Accept(v Visitor, path string) error { return v.VisitFolder(path, f) }
+func (l *Link) Accept(v Visitor, path string) error   { return v.VisitLink(path, l) }
+
+// Visit walks the tree in order and lets every node Accept v.
+func Visit(root Component, v Visitor) error {
+	return Walk(root, func(path string, c Component) error { return c.Accept(v, path) }, nil)
+}
+
+// -- Visitors --
+
+// SizeVisitor adds everything up.
+type SizeVisitor struct {
+	Files, Folders, Links int
+	Others                int // leaves of any other kind
+	Bytes                 int
+}
+
+func (s *SizeVisitor) VisitFile(path string, f *File) error {
+	s.Files++
+	s.Bytes += f.Size()
+	return nil
+}
+
+func (s *SizeVisitor) VisitFolder(path string, f *Folder) error {
+	s.Folders++
+	return nil
+}
+
+func (s *SizeVisitor) VisitLink(path string, l *Link) error {
+	s.Links++
+	return nil
+}
+
+func (s *SizeVisitor) VisitLeaf(path string, c Component) error {
+	s.Others++
+	s.Bytes += c.Size()
+	return nil
+}
+
+// TreePrinter draws the tree with one indented line per node, like the `tree` command.
+type TreePrinter struct {
+	W io.Writer
+}
+
+func (t *TreePrinter) line(path, text string) error {
+	_, err := fmt.Fprintf(t.W, "%s%s\n", strings.Repeat("  ", strings.Count(path, "/")), text)
+	return err
+}
+
+func (t *TreePrinter) VisitFile(path string, f *File) error {
+	return t.line(path, fmt.Sprintf("%s (%d bytes)", f.Name, f.Size()))
+}
+
+func (t *TreePrinter) VisitFolder(path string, f *Folder) error {
+	return t.line(path, f.Name+"/")
+}
+
+func (t *TreePrinter) VisitLink(path string, l *Link) error {
+	return t.line(path, l.Name+" -> "+l.Target)
+}
+
+func (t *TreePrinter) VisitLeaf(path string, c Component) error {
+	return t.line(path, fmt.Sprintf("%s (%d bytes)", c.label(), c.Size()))
+}
+
+// CSVExporter writes one row per node: path, type, bytes. Call Flush when done.
+type CSVExporter struct {
+	w *csv.Writer
+}
+
+func NewCSVExporter(w io.Writer) *CSVExporter {
+	e := &CSVExporter{w: csv.NewWriter(w)}
+	e.w.Write([]string{"path", "type", "bytes"})
+	return e
+}
+
+func (e *CSVExporter) VisitFile(path string, f *File) error {
+	return e.w.Write([]string{path, "file", strconv.Itoa(f.Size())})
+}
+
+func (e *CSVExporter) VisitFolder(path string, f *Folder) error {
+	return e.w.Write([]string{path, "folder", ""})
+}
+
+func (e *CSVExporter) VisitLink(path string, l *Link) error {
+	return e.w.Write([]string{path, "link", ""})
+}
+
+func (e *CSVExporter) VisitLeaf(path string, c Component) error {
+	return e.w.Write([]string{path, "leaf", strconv.Itoa(c.Size())})
+}
+
+func (e *CSVExporter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// SearchVisitor does what Component.Search does, one node at a time.
+type SearchVisitor struct {
+	Query   *Query
+	Matches []Match
+}
+
+// add keeps a leaf's own matches, which only know the leaf's name, under its full path.
+func (s *SearchVisitor) add(path string, matches []Match) {
+	for _, m := range matches {
+		m.Path = path
+		s.Matches = append(s.Matches, m)
+	}
+}
+
+func (s *SearchVisitor) VisitFile(path string, f *File) error {
+	s.add(path, f.Search(s.Query))
+	return nil
+}
+
+func (s *SearchVisitor) VisitFolder(path string, f *Folder) error { return nil }
+
+func (s *SearchVisitor) VisitLink(path string, l *Link) error {
+	s.add(path, l.Search(s.Query))
+	return nil
+}
+
+func (s *SearchVisitor) VisitLeaf(path string, c Component) error {
+	s.add(path, c.Search(s.Query))
+	return nil
+}
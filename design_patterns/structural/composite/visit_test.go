@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+// visitTree is
+//
+//	Root
+//	├─ a.txt "rose\nred"
+//	├─ n (a note, 3 pages)
+//	└─ Docs
+//	   ├─ b.txt "a rose"
+//	   └─ l -> Root/a.txt
+func visitTree() *Folder {
+	root, docs := &Folder{Name: "Root"}, &Folder{Name: "Docs"}
+	root.Add(NewFile("a.txt", "rose\nred"))
+	root.Add(&note{Name: "n", Pages: 3})
+	root.Add(docs)
+	docs.Add(NewFile("b.txt", "a rose"))
+	docs.Add(&Link{Name: "l", Target: "Root/a.txt"})
+	return root
+}
+
+func TestVisitorsHandleOtherLeaves(t *testing.T) {
+	root := visitTree()
+
+	var sizes SizeVisitor
+	if err := Visit(root, &sizes); err != nil {
+		t.Fatal(err)
+	}
+	if want := (SizeVisitor{Files: 2, Folders: 2, Links: 1, Others: 1, Bytes: 14}); sizes != want {
+		t.Errorf("sizes = %+v, want %+v", sizes, want)
+	}
+
+	var tree bytes.Buffer
+	Visit(root, &TreePrinter{W: &tree})
+	wantTree := "Root/\n  a.txt (8 bytes)\n  n (0 bytes)\n  Docs/\n    b.txt (6 bytes)\n    l -> Root/a.txt\n"
+	if tree.String() != wantTree {
+		t.Errorf("tree:\n%s\nwant:\n%s", tree.String(), wantTree)
+	}
+
+	var csv bytes.Buffer
+	exporter := NewCSVExporter(&csv)
+	Visit(root, exporter)
+	if err := exporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "path,type,bytes\nRoot,folder,\nRoot/a.txt,file,8\nRoot/n,leaf,0\nRoot/Docs,folder,\nRoot/Docs/b.txt,file,6\nRoot/Docs/l,link,\n"
+	if csv.String() != wantCSV {
+		t.Errorf("csv:\n%s\nwant:\n%s", csv.String(), wantCSV)
+	}
+}
+
+func TestSearchVisitorAgreesWithSearch(t *testing.T) {
+	root := visitTree()
+	for _, keyword := range []string{"rose", "a.txt", "red", "nothing"} {
+		query, _ := NewQuery(keyword)
+		finder := &SearchVisitor{Query: query}
+		if err := Visit(root, finder); err != nil {
+			t.Fatal(err)
+		}
+		if want := root.Search(query); !slices.Equal(finder.Matches, want) {
+			t.Errorf("%q: visitor found %v, Search found %v", keyword, finder.Matches, want)
+		}
+	}
+}
+
+func TestWalkSkipSubtree(t *testing.T) {
+	root := visitTree()
+	var pre, post []string
+	err := Walk(root,
+		func(path string, c Component) error {
+			pre = append(pre, path)
+			if c.label() == "Docs" {
+				return SkipSubtree
+			}
+			return nil
+		},
+		func(path string, c Component) error {
+			post = append(post, path)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Root", "Root/a.txt", "Root/n", "Root/Docs"}; !slices.Equal(pre, want) {
+		t.Errorf("pre = %v, want %v", pre, want)
+	}
+	if want := []string{"Root/a.txt", "Root/n", "Root"}; !slices.Equal(post, want) {
+		t.Errorf("post = %v, want %v", post, want)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gostudy/design_patterns/structural/internal/codegen"
+)
+
+// decoratorgen
+//
+// 5-Year-Old Explanation:
+// Writing a new topping for the ice cream means copying every method by hand:
+// "GetCost? Ask the ice cream inside. GetDescription? Ask the ice cream inside."
+// This little robot reads the IceCream interface and writes that boring part for you.
+//
+// Real World Scenario:
+// Big service interfaces have dozens of methods. A base decorator that forwards all of them
+// lets you write a new decorator by overriding only the one or two methods you care about.
+//
+// Usage (from a go:generate line in the package that declares the interface):
+//
+//	//go:generate go run ./decoratorgen -type IceCream
+//
+// For an interface named X it writes x_decorator.go containing:
+//   - XDecorator: a struct that forwards every method of X to Next,
+//     with optional Before/After hooks that see the method name, arguments and results.
+//   - NewXLogger: a logging decorator built on top of XDecorator's hooks.
+
+var (
+	typeName = flag.String("type", "", "name of the interface to decorate (required)")
+	output   = flag.String("output", "", "output file name; default <type>_decorator.go")
+	dir      = flag.String("dir", ".", "directory of the package that declares the interface")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("decoratorgen: ")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_decorator.go"
+	}
+
+	src, err := Generate(*dir, *typeName, filepath.Base(*output))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Generate type-checks the package in dir and returns the formatted decorator source for
+// the named interface. The file called skip (usually the previous output) is left out so a
+// stale generated file can never stop the generator from running.
+func Generate(dir, name, skip string) ([]byte, error) {
+	pkg, err := codegen.LoadPackage(dir, skip, name+"Decorator", "New"+name+"Logger")
+	if err != nil {
+		return nil, err
+	}
+	iface, err := codegen.LookupInterface(pkg, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		switch m := iface.Method(i).Name(); m {
+		case "Next", "Before", "After":
+			return nil, fmt.Errorf("method %s.%s clashes with a decorator field", name, m)
+		}
+	}
+
+	g := &generator{File: codegen.NewFile(pkg)}
+	g.emit(name, iface)
+	return g.Source("decoratorgen")
+}
+
+type generator struct {
+	*codegen.File
+}
+
+func (g *generator) emit(name string, iface *types.Interface) {
+	deco := name + "Decorator"
+
+	g.Printf("// %s forwards every %s method to Next.\n", deco, name)
+	g.Printf("// Embed it in your own decorator and override only the methods you want to change.\n")
+	g.Printf("// Before and After are optional hooks that run around every forwarded call.\n")
+	g.Printf("type %s struct {\n", deco)
+	g.Printf("Next %s\n", name)
+	g.Printf("Before func(method string, args []any)\n")
+	g.Printf("After func(method string, results []any)\n")
+	g.Printf("}\n\n")
+
+	for i := 0; i < iface.NumMethods(); i++ {
+		g.emitMethod(deco, iface.Method(i))
+	}
+
+	fmtName := g.Import("fmt", "fmt")
+	g.Printf("// New%sLogger returns a decorator that logs every %s call and its results.\n", name, name)
+	g.Printf("// logf defaults to fmt.Printf style output on stdout when nil.\n")
+	g.Printf("func New%sLogger(next %s, logf func(format string, args ...any)) *%s {\n", name, name, deco)
+	g.Printf("if logf == nil {\n")
+	g.Printf("logf = func(format string, args ...any) { %s.Printf(format+\"\\n\", args...) }\n", fmtName)
+	g.Printf("}\n")
+	g.Printf("return &%s{\n", deco)
+	g.Printf("Next: next,\n")
+	g.Printf("Before: func(method string, args []any) { logf(\"%s.%%s called with %%v\", method, args) },\n", name)
+	g.Printf("After: func(method string, results []any) { logf(\"%s.%%s returned %%v\", method, results) },\n", name)
+	g.Printf("}\n")
+	g.Printf("}\n")
+}
+
+func (g *generator) emitMethod(recv string, m *types.Func) {
+	sig := m.Type().(*types.Signature)
+	decl, args, call := g.Params(sig)
+	_, rnames, rtype := g.Results(sig)
+
+	g.Printf("func (d *%s) %s(%s) %s {\n", recv, m.Name(), strings.Join(decl, ", "), rtype)
+	g.Printf("if d.Before != nil {\n")
+	g.Printf("d.Before(%q, []any{%s})\n", m.Name(), strings.Join(args, ", "))
+	g.Printf("}\n")
+	invoke := fmt.Sprintf("d.Next.%s(%s)", m.Name(), strings.Join(call, ", "))
+	if len(rnames) == 0 {
+		g.Printf("%s\n", invoke)
+	} else {
+		g.Printf("%s := %s\n", strings.Join(rnames, ", "), invoke)
+	}
+	g.Printf("if d.After != nil {\n")
+	g.Printf("d.After(%q, []any{%s})\n", m.Name(), strings.Join(rnames, ", "))
+	g.Printf("}\n")
+	if len(rnames) > 0 {
+		g.Printf("return %s\n", strings.Join(rnames, ", "))
+	}
+	g.Printf("}\n\n")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gostudy/design_patterns/structural/internal/codegen"
+)
+
+const fixture = "testdata/fixture"
+
+func TestGenerateCompiles(t *testing.T) {
+	src, err := Generate(fixture, "Service", "service_decorator.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		// Methods come in name order: Parse takes text/template first, Render gets the alias.
+		`template2 "html/template"`,
+		"a0 *template2.Template",
+		`"text/template"`,
+		"func (d *ServiceDecorator) Close() error",
+		"func (d *ServiceDecorator) Name() string",
+		"a1 ...any",
+		"d.Next.Sum(a0...)",
+		"r0, r1, r2 := d.Next.Split(a0)",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("output is missing %q:\n%s", want, src)
+		}
+	}
+
+	// The fixture plus the generated file must build and pass go vet.
+	if err := codegen.Compile(fixture, map[string][]byte{"service_decorator.go": src}); err != nil {
+		t.Fatalf("generated code doesn't compile: %v", err)
+	}
+}
+
+func TestGenerateRejects(t *testing.T) {
+	for name, want := range map[string]string{
+		"Missing":        "not found",
+		"NotAnInterface": "not an interface",
+		"Clashing":       "clashes with a decorator field",
+	} {
+		if _, err := Generate(fixture, name, "check.go"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Generate(%s) = %v, want an error containing %q", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package fixture
+
+// Doesn't compile until the decorator exists, just like a real package using it.
+var (
+	_ Service = (*ServiceDecorator)(nil)
+	_ Service = NewServiceLogger(nil, nil)
+)
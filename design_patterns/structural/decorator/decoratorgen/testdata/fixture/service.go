@@ -0,0 +1,32 @@
+package fixture
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+	"time"
+)
+
+// Named is embedded in Service, so its method must be forwarded too.
+type Named interface {
+	Name() string
+}
+
+// Service has a bit of everything the generator has to get right.
+type Service interface {
+	Named
+	io.Closer
+	Render(t *htmltemplate.Template, data any) (string, error)
+	Parse(t *template.Template, text string) (*template.Template, error)
+	Logf(format string, args ...any)
+	Sum(xs ...int) int
+	Split(s string) (head string, tail []string, ok bool)
+	Wait(d time.Duration) <-chan time.Time
+	Ping()
+}
+
+type NotAnInterface struct{}
+
+type Clashing interface {
+	Next() Service
+}
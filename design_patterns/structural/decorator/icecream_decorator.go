@@ -0,0 +1,51 @@
+// Code generated by decoratorgen; DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+)
+
+// IceCreamDecorator forwards every IceCream method to Next.
+// Embed it in your own decorator and override only the methods you want to change.
+// Before and After are optional hooks that run around every forwarded call.
+type IceCreamDecorator struct {
+	Next   IceCream
+	Before func(method string, args []any)
+	After  func(method string, results []any)
+}
+
+func (d *IceCreamDecorator) GetCost() int {
+	if d.Before != nil {
+		d.Before("GetCost", []any{})
+	}
+	r0 := d.Next.GetCost()
+	if d.After != nil {
+		d.After("GetCost", []any{r0})
+	}
+	return r0
+}
+
+func (d *IceCreamDecorator) GetDescription() string {
+	if d.Before != nil {
+		d.Before("GetDescription", []any{})
+	}
+	r0 := d.Next.GetDescription()
+	if d.After != nil {
+		d.After("GetDescription", []any{r0})
+	}
+	return r0
+}
+
+// NewIceCreamLogger returns a decorator that logs every IceCream call and its results.
+// logf defaults to fmt.Printf style output on stdout when nil.
+func NewIceCreamLogger(next IceCream, logf func(format string, args ...any)) *IceCreamDecorator {
+	if logf == nil {
+		logf = func(format string, args ...any) { fmt.Printf(format+"\n", args...) }
+	}
+	return &IceCreamDecorator{
+		Next:   next,
+		Before: func(method string, args []any) { logf("IceCream.%s called with %v", method, args) },
+		After:  func(method string, results []any) { logf("IceCream.%s returned %v", method, results) },
+	}
+}
@@ -1,6 +1,12 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//go:generate go run ./decoratorgen -type IceCream
 
 // Decorator Pattern
 //
@@ -77,4 +83,30 @@ func main() {
 	// 3. Add Sprinkles
 	myIceCream = &Sprinkles{iceCream: myIceCream}
 	fmt.Printf("Order 3: %s. Cost: $%d\n", myIceCream.GetDescription(), myIceCream.GetCost())
+
+	// 4. A generated decorator: IceCreamDecorator (see icecream_decorator.go) forwards every method,
+	// so a logger is just a decorator with Before/After hooks.
+	logged := NewIceCreamLogger(myIceCream, nil)
+	fmt.Printf("Order 4: %s. Cost: $%d\n", logged.GetDescription(), logged.GetCost())
+
+	// 5. The same idea with streams: stack decorators around an io.Writer
+	fmt.Println("\n--- Stream Decorators: Toppings for io.Reader/io.Writer ---")
+	menu := "vanilla\nchocolate\nsprinkles\n"
+
+	var src io.Reader = strings.NewReader(menu)
+	counter := NewCountingReader(src)
+	checksum := NewChecksumReader(counter)
+	limited := NewRateLimitedReader(checksum, NewTokenBucket(1024, 8))
+	progress := NewProgressReader(limited, int64(len(menu)), func(done, total int64) {
+		fmt.Printf("Progress: %d/%d bytes\n", done, total)
+	})
+
+	var printed strings.Builder
+	out := NewCountingWriter(NewPrefixWriter(&printed, "[menu] "))
+	if _, err := io.Copy(out, progress); err != nil {
+		fmt.Println("Copy failed:", err)
+		return
+	}
+	fmt.Print(printed.String())
+	fmt.Printf("Read %d bytes, wrote %d bytes, sha256=%s\n", counter.Count(), out.Count(), checksum.Sum())
 }
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Stream Decorators
+//
+// The ice cream toppings wrap an IceCream and still look like an IceCream.
+// Go's most famous decorators do the same thing with streams:
+// every type below wraps an io.Reader or io.Writer and is itself an io.Reader or io.Writer,
+// so you can stack them like toppings: Progress(RateLimit(Checksum(Count(file)))).
+//
+// Rules every decorator here follows:
+// - Readers pass through whatever the wrapped reader returns (including io.EOF) untouched,
+//   and only look at p[:n], the bytes that were actually read.
+// - Writers only count/hash the n bytes the wrapped writer accepted, and return
+//   io.ErrShortWrite if the wrapped writer wrote less than asked without an error.
+
+// -- Byte Counting --
+
+// CountingReader counts how many bytes have passed through it.
+type CountingReader struct {
+	r io.Reader
+	n int64
+}
+
+func NewCountingReader(r io.Reader) *CountingReader {
+	return &CountingReader{r: r}
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Count returns the number of bytes read so far.
+func (c *CountingReader) Count() int64 {
+	return c.n
+}
+
+// CountingWriter counts how many bytes the wrapped writer accepted.
+type CountingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, shortWrite(n, len(p), err)
+}
+
+// Count returns the number of bytes written so far.
+func (c *CountingWriter) Count() int64 {
+	return c.n
+}
+
+// -- SHA-256 Checksum Tee --
+
+// ChecksumReader hashes every byte that is read through it.
+type ChecksumReader struct {
+	r io.Reader
+	h hash.Hash
+}
+
+func NewChecksumReader(r io.Reader) *ChecksumReader {
+	return &ChecksumReader{r: r, h: sha256.New()}
+}
+
+func (c *ChecksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n]) // hash.Hash.Write never returns an error
+	return n, err
+}
+
+// Sum returns the hex encoded SHA-256 of everything read so far.
+func (c *ChecksumReader) Sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+// ChecksumWriter hashes every byte the wrapped writer accepted.
+type ChecksumWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+func NewChecksumWriter(w io.Writer) *ChecksumWriter {
+	return &ChecksumWriter{w: w, h: sha256.New()}
+}
+
+func (c *ChecksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.h.Write(p[:n])
+	return n, shortWrite(n, len(p), err)
+}
+
+// Sum returns the hex encoded SHA-256 of everything written so far.
+func (c *ChecksumWriter) Sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+// -- Token Bucket Rate Limiting --
+
+// TokenBucket hands out one token per byte.
+// It refills at `rate` tokens per second and never holds more than `burst` tokens.
+// One bucket can be shared by several readers/writers to give them a common budget.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time    // for tests; nil means time.Now
+	sleep func(time.Duration) // for tests; nil means time.Sleep
+}
+
+// NewTokenBucket panics if bytesPerSecond isn't a positive, finite number:
+// a bucket that never refills would make Wait spin forever.
+func NewTokenBucket(bytesPerSecond float64, burst int) *TokenBucket {
+	if !(bytesPerSecond > 0) || math.IsInf(bytesPerSecond, 1) {
+		panic(fmt.Sprintf("NewTokenBucket: rate must be positive and finite, got %v", bytesPerSecond))
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   bytesPerSecond,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until n tokens are available and takes them.
+// Requests bigger than the burst are taken in burst-sized pieces.
+func (b *TokenBucket) Wait(n int) {
+	for n > 0 {
+		take := min(n, b.burst)
+		b.mu.Lock()
+		now := time.Now()
+		if b.now != nil {
+			now = b.now()
+		}
+		b.tokens = min(float64(b.burst), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		var delay time.Duration
+		if b.tokens >= float64(take) {
+			b.tokens -= float64(take)
+			n -= take
+		} else {
+			delay = time.Duration((float64(take) - b.tokens) / b.rate * float64(time.Second))
+		}
+		b.mu.Unlock()
+		switch {
+		case delay <= 0:
+		case b.sleep != nil:
+			b.sleep(delay)
+		default:
+			time.Sleep(delay)
+		}
+	}
+}
+
+// RateLimitedReader slows reads down to the bucket's rate.
+type RateLimitedReader struct {
+	r      io.Reader
+	bucket *TokenBucket
+}
+
+func NewRateLimitedReader(r io.Reader, bucket *TokenBucket) *RateLimitedReader {
+	return &RateLimitedReader{r: r, bucket: bucket}
+}
+
+func (l *RateLimitedReader) Read(p []byte) (int, error) {
+	// Never read more than one burst at a time, then pay for what we actually got.
+	if len(p) > l.bucket.burst {
+		p = p[:l.bucket.burst]
+	}
+	n, err := l.r.Read(p)
+	l.bucket.Wait(n)
+	return n, err
+}
+
+// RateLimitedWriter slows writes down to the bucket's rate.
+type RateLimitedWriter struct {
+	w      io.Writer
+	bucket *TokenBucket
+}
+
+func NewRateLimitedWriter(w io.Writer, bucket *TokenBucket) *RateLimitedWriter {
+	return &RateLimitedWriter{w: w, bucket: bucket}
+}
+
+func (l *RateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := p[written:min(len(p), written+l.bucket.burst)]
+		l.bucket.Wait(len(chunk))
+		n, err := l.w.Write(chunk)
+		written += n
+		if err := shortWrite(n, len(chunk), err); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// -- Line Prefixing --
+
+// PrefixWriter puts a prefix in front of every line, like "[server] ".
+// The returned count is in terms of the caller's bytes, never the prefix bytes,
+// so io.Copy and friends keep working.
+type PrefixWriter struct {
+	w       io.Writer
+	prefix  []byte
+	pending []byte // part of the prefix still owed before the next byte of p
+	midLine bool
+}
+
+func NewPrefixWriter(w io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{w: w, prefix: []byte(prefix)}
+}
+
+func (pw *PrefixWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		if !pw.midLine && pw.pending == nil {
+			pw.pending = pw.prefix
+		}
+		if len(pw.pending) > 0 {
+			n, err := pw.w.Write(pw.pending)
+			pw.pending = pw.pending[n:]
+			if err := shortWrite(n, n+len(pw.pending), err); err != nil {
+				return written, err
+			}
+		}
+		pw.midLine = true
+
+		// Write up to and including the next newline.
+		start, end := written, len(p)
+		if i := bytes.IndexByte(p[start:], '\n'); i >= 0 {
+			end = start + i + 1
+		}
+		n, err := pw.w.Write(p[start:end])
+		written += n
+		if n > 0 && p[written-1] == '\n' {
+			pw.midLine = false
+			pw.pending = nil
+		}
+		if err := shortWrite(n, end-start, err); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// -- Progress Callback --
+
+// ProgressFunc is told how many bytes are done out of the expected total.
+// total is -1 when the size is unknown.
+type ProgressFunc func(done, total int64)
+
+// ProgressReader reports progress after every successful read.
+type ProgressReader struct {
+	r          io.Reader
+	done       int64
+	total      int64
+	onProgress ProgressFunc
+}
+
+func NewProgressReader(r io.Reader, total int64, fn ProgressFunc) *ProgressReader {
+	return &ProgressReader{r: r, total: total, onProgress: fn}
+}
+
+func (pr *ProgressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.done += int64(n)
+		pr.onProgress(pr.done, pr.total)
+	}
+	return n, err
+}
+
+// ProgressWriter reports progress after every write that accepted bytes.
+type ProgressWriter struct {
+	w          io.Writer
+	done       int64
+	total      int64
+	onProgress ProgressFunc
+}
+
+func NewProgressWriter(w io.Writer, total int64, fn ProgressFunc) *ProgressWriter {
+	return &ProgressWriter{w: w, total: total, onProgress: fn}
+}
+
+func (pw *ProgressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	if n > 0 {
+		pw.done += int64(n)
+		pw.onProgress(pw.done, pw.total)
+	}
+	return n, shortWrite(n, len(p), err)
+}
+
+// shortWrite follows the io.Writer contract: a write that stops early must explain why.
+func shortWrite(n, want int, err error) error {
+	if err == nil && n < want {
+		return io.ErrShortWrite
+	}
+	return err
+}
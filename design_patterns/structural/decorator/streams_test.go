@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+var content = []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 50))
+
+func fastBucket() *TokenBucket { return NewTokenBucket(1<<30, 64) }
+
+// Every reader decorator must behave like a well-formed io.Reader over the same bytes.
+func TestReadersAreWellBehaved(t *testing.T) {
+	readers := map[string]func(io.Reader) io.Reader{
+		"counting":     func(r io.Reader) io.Reader { return NewCountingReader(r) },
+		"checksum":     func(r io.Reader) io.Reader { return NewChecksumReader(r) },
+		"rate-limited": func(r io.Reader) io.Reader { return NewRateLimitedReader(r, fastBucket()) },
+		"progress":     func(r io.Reader) io.Reader { return NewProgressReader(r, -1, func(int64, int64) {}) },
+	}
+	sources := map[string]func() io.Reader{
+		"plain":    func() io.Reader { return bytes.NewReader(content) },
+		"one-byte": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(content)) },
+		"half":     func() io.Reader { return iotest.HalfReader(bytes.NewReader(content)) },
+	}
+	for rname, wrap := range readers {
+		for sname, src := range sources {
+			t.Run(rname+"/"+sname, func(t *testing.T) {
+				if err := iotest.TestReader(wrap(src()), content); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+func TestReadersPassErrorsThrough(t *testing.T) {
+	boom := errors.New("boom")
+	readers := map[string]io.Reader{
+		"counting":     NewCountingReader(iotest.ErrReader(boom)),
+		"checksum":     NewChecksumReader(iotest.ErrReader(boom)),
+		"rate-limited": NewRateLimitedReader(iotest.ErrReader(boom), fastBucket()),
+		"progress":     NewProgressReader(iotest.ErrReader(boom), -1, func(int64, int64) { t.Error("progress on error") }),
+	}
+	for name, r := range readers {
+		if n, err := r.Read(make([]byte, 8)); n != 0 || err != boom {
+			t.Errorf("%s: Read = %d, %v; want 0, boom", name, n, err)
+		}
+	}
+}
+
+func TestCountingAndChecksum(t *testing.T) {
+	want := sha256.Sum256(content)
+
+	cr := NewCountingReader(iotest.OneByteReader(bytes.NewReader(content)))
+	sr := NewChecksumReader(cr)
+	if _, err := io.Copy(io.Discard, sr); err != nil {
+		t.Fatal(err)
+	}
+	if cr.Count() != int64(len(content)) || sr.Sum() != hex.EncodeToString(want[:]) {
+		t.Errorf("reader: count %d, sum %s", cr.Count(), sr.Sum())
+	}
+
+	var out bytes.Buffer
+	cw := NewCountingWriter(&out)
+	sw := NewChecksumWriter(cw)
+	if _, err := io.Copy(sw, iotest.HalfReader(bytes.NewReader(content))); err != nil {
+		t.Fatal(err)
+	}
+	if cw.Count() != int64(len(content)) || sw.Sum() != hex.EncodeToString(want[:]) || !bytes.Equal(out.Bytes(), content) {
+		t.Errorf("writer: count %d, sum %s", cw.Count(), sw.Sum())
+	}
+}
+
+// shortWriter accepts at most max bytes per call and never complains about it.
+type shortWriter struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (s *shortWriter) Write(p []byte) (int, error) {
+	return s.buf.Write(p[:min(len(p), s.max)])
+}
+
+func TestWritersReportShortWrites(t *testing.T) {
+	writers := map[string]func(io.Writer) io.Writer{
+		"counting":     func(w io.Writer) io.Writer { return NewCountingWriter(w) },
+		"checksum":     func(w io.Writer) io.Writer { return NewChecksumWriter(w) },
+		"rate-limited": func(w io.Writer) io.Writer { return NewRateLimitedWriter(w, fastBucket()) },
+		"prefix":       func(w io.Writer) io.Writer { return NewPrefixWriter(w, "") },
+		"progress":     func(w io.Writer) io.Writer { return NewProgressWriter(w, -1, func(int64, int64) {}) },
+	}
+	for name, wrap := range writers {
+		sw := &shortWriter{max: 5}
+		n, err := wrap(sw).Write([]byte("hello world"))
+		if n != 5 || err != io.ErrShortWrite {
+			t.Errorf("%s: Write = %d, %v; want 5, io.ErrShortWrite", name, n, err)
+		}
+	}
+}
+
+func TestWritersOverTruncateWriter(t *testing.T) {
+	var out bytes.Buffer
+	cw := NewCountingWriter(iotest.TruncateWriter(&out, 10))
+	n, err := cw.Write(content)
+	if n != len(content) || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	// TruncateWriter claims it took everything, so that's what gets counted.
+	if cw.Count() != int64(len(content)) || out.Len() != 10 {
+		t.Errorf("count %d, kept %d", cw.Count(), out.Len())
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	pw := NewPrefixWriter(&out, "> ")
+	// Split mid-line and across newlines, one byte at a time.
+	for _, b := range []byte("one\ntwo\n\nthree") {
+		if n, err := pw.Write([]byte{b}); n != 1 || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	if want := "> one\n> two\n> \n> three"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestProgressReachesTotal(t *testing.T) {
+	var last, total int64
+	r := NewProgressReader(iotest.OneByteReader(bytes.NewReader(content)), int64(len(content)),
+		func(done, tot int64) { last, total = done, tot })
+	io.Copy(io.Discard, r)
+	if last != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("progress ended at %d/%d", last, total)
+	}
+}
+
+// fakeTime makes b read a clock that only moves when b sleeps. It returns the time slept so far.
+func fakeTime(b *TokenBucket) (slept func() time.Duration) {
+	var total time.Duration
+	start := b.last
+	b.now = func() time.Time { return start.Add(total) }
+	b.sleep = func(d time.Duration) { total += d }
+	return func() time.Duration { return total }
+}
+
+func TestRateLimitedWriterWaits(t *testing.T) {
+	bucket := NewTokenBucket(100, 10) // 100 bytes a second, 10 at once
+	slept := fakeTime(bucket)
+
+	var out bytes.Buffer
+	w := NewRateLimitedWriter(&out, bucket)
+	if n, err := w.Write(make([]byte, 50)); n != 50 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	// The first 10 bytes are the full bucket; the other 40 take 0.4s to earn.
+	if got, want := slept(), 400*time.Millisecond; got < want-time.Millisecond || got > want+time.Millisecond {
+		t.Errorf("slept %v, want %v", got, want)
+	}
+	if out.Len() != 50 {
+		t.Errorf("wrote %d bytes", out.Len())
+	}
+}
+
+func TestRateLimitedReadersShareABucket(t *testing.T) {
+	bucket := NewTokenBucket(1000, 100)
+	slept := fakeTime(bucket)
+
+	a := NewRateLimitedReader(bytes.NewReader(make([]byte, 300)), bucket)
+	b := NewRateLimitedReader(bytes.NewReader(make([]byte, 300)), bucket)
+	io.Copy(io.Discard, a)
+	io.Copy(io.Discard, b)
+	// 600 bytes at 1000 a second, minus the 100 already in the bucket.
+	if got, want := slept(), 500*time.Millisecond; got < want-time.Millisecond || got > want+time.Millisecond {
+		t.Errorf("slept %v, want %v", got, want)
+	}
+}
+
+func TestNewTokenBucketRejectsBadRates(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewTokenBucket(%v) didn't panic", rate)
+				}
+			}()
+			NewTokenBucket(rate, 1)
+		}()
+	}
+}
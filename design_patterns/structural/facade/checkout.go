@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// One-Click Checkout
+//
+// The "Real World Scenario" from the top of main.go. Four subsystems, each behind an
+// interface so the real services can be swapped for the in-memory fakes below:
+// - Inventory holds the items so nobody else buys them while we're busy.
+// - ShippingCalculator prices the delivery.
+// - PaymentGateway charges the card (items + shipping).
+// - Mailer sends the confirmation.
+// PlaceOrder runs them in that order. If shipping or payment fails, the held items are
+// released again (the same rollback as PlayGame). Once the card is charged the order
+// stands: a failed email is reported on the Receipt, not treated as a failed order.
+//
+// Every order carries an idempotency key. Clicking "Buy" twice, or a retry after a
+// timeout, sends the same key, and gets back the first order instead of a second charge.
+// A finished order is remembered by its key for keyTTL, then forgotten.
+
+var (
+	ErrNoItems      = errors.New("nothing to order")
+	ErrUnknownItem  = errors.New("unknown item")
+	ErrBadQuantity  = errors.New("quantity must be at least 1")
+	ErrOutOfStock   = errors.New("out of stock")
+	ErrNoShipping   = errors.New("we don't ship there")
+	ErrCardDeclined = errors.New("card declined")
+	ErrMissingKey   = errors.New("an idempotency key is required")
+	ErrKeyReused    = errors.New("idempotency key already used for a different order")
+)
+
+// Cents is an amount of money. Never use floats for money.
+type Cents int64
+
+func (c Cents) String() string {
+	sign, abs := "", uint64(c)
+	if c < 0 {
+		sign, abs = "-", -abs // uint64, so even the smallest Cents has an absolute value
+	}
+	return fmt.Sprintf("%s$%d.%02d", sign, abs/100, abs%100)
+}
+
+type LineItem struct {
+	SKU      string
+	Quantity int
+}
+
+// OrderRequest is everything the "Buy" button sends.
+type OrderRequest struct {
+	IdempotencyKey string // the same for every retry of this order
+	Email          string
+	Country        string
+	Card           string
+	Items          []LineItem
+}
+
+func (r OrderRequest) validate() error {
+	if len(r.Items) == 0 {
+		return ErrNoItems
+	}
+	for _, it := range r.Items {
+		if it.Quantity <= 0 {
+			return fmt.Errorf("%s x%d: %w", it.SKU, it.Quantity, ErrBadQuantity)
+		}
+	}
+	return nil
+}
+
+// sameOrder reports whether o may reuse r's key: everything but the key must match.
+func (r OrderRequest) sameOrder(o OrderRequest) bool {
+	return r.Email == o.Email && r.Country == o.Country && r.Card == o.Card && slices.Equal(r.Items, o.Items)
+}
+
+type Receipt struct {
+	OrderID   string
+	Subtotal  Cents
+	Shipping  Cents
+	Total     Cents
+	ChargeID  string
+	EmailSent bool
+	EmailErr  error // why the confirmation didn't go out, if it didn't
+}
+
+// -- Subsystems --
+
+type Reservation struct {
+	ID       string
+	Subtotal Cents
+}
+
+type Inventory interface {
+	Reserve(items []LineItem) (Reservation, error)
+	Release(reservationID string) error
+}
+
+type ShippingCalculator interface {
+	Quote(country string, items []LineItem) (Cents, error)
+}
+
+type PaymentGateway interface {
+	// Charge must be idempotent too: the same key never charges twice.
+	Charge(idempotencyKey, card string, amount Cents) (chargeID string, err error)
+}
+
+type Mailer interface {
+	SendConfirmation(email string, r Receipt) error
+}
+
+// -- The Facade --
+
+// keyTTL is how long a finished order answers retries of its idempotency key.
+const keyTTL = 24 * time.Hour
+
+type CheckoutFacade struct {
+	inventory Inventory
+	shipping  ShippingCalculator
+	payments  PaymentGateway
+	mailer    Mailer
+	now       func() time.Time // for tests; nil means time.Now
+
+	mu        sync.Mutex
+	orders    map[string]*orderAttempt // by idempotency key
+	nextID    int
+	lastSweep time.Time
+}
+
+// orderAttempt is one PlaceOrder in progress or finished. done is closed when it finishes.
+type orderAttempt struct {
+	req      OrderRequest
+	done     chan struct{}
+	finished time.Time // zero while running; guarded by CheckoutFacade.mu
+	receipt  *Receipt
+	err      error
+}
+
+// expired reports whether a finished attempt is too old to answer for its key.
+func (a *orderAttempt) expired(now time.Time) bool {
+	return !a.finished.IsZero() && now.Sub(a.finished) >= keyTTL
+}
+
+func NewCheckoutFacade(inventory Inventory, shipping ShippingCalculator, payments PaymentGateway, mailer Mailer) *CheckoutFacade {
+	return &CheckoutFacade{
+		inventory: inventory,
+		shipping:  shipping,
+		payments:  payments,
+		mailer:    mailer,
+		orders:    map[string]*orderAttempt{},
+	}
+}
+
+// PlaceOrder is the one-click button.
+// A retry with the same key returns the first successful order (waiting for it if it's
+// still running). A retry after a failure tries again; the key is also handed to the
+// PaymentGateway, so even then a card is never charged twice for one key.
+func (c *CheckoutFacade) PlaceOrder(req OrderRequest) (*Receipt, error) {
+	if req.IdempotencyKey == "" {
+		return nil, ErrMissingKey
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	now := c.clock()
+
+	c.mu.Lock()
+	if now.Sub(c.lastSweep) >= keyTTL {
+		c.sweep(now)
+	}
+	if prev, ok := c.orders[req.IdempotencyKey]; ok && !prev.expired(now) {
+		c.mu.Unlock()
+		if !prev.req.sameOrder(req) {
+			return nil, fmt.Errorf("key %q: %w", req.IdempotencyKey, ErrKeyReused)
+		}
+		<-prev.done
+		if prev.err == nil {
+			return prev.receipt, nil
+		}
+		c.mu.Lock()
+		if c.orders[req.IdempotencyKey] == prev {
+			delete(c.orders, req.IdempotencyKey)
+		}
+		c.mu.Unlock()
+		return c.PlaceOrder(req)
+	}
+	saved := req
+	saved.Items = slices.Clone(req.Items) // the caller may reuse its slice
+	attempt := &orderAttempt{req: saved, done: make(chan struct{})}
+	c.orders[req.IdempotencyKey] = attempt
+	c.nextID++
+	orderID := fmt.Sprintf("order-%d", c.nextID)
+	c.mu.Unlock()
+
+	attempt.receipt, attempt.err = c.placeOrder(orderID, req)
+	c.mu.Lock()
+	attempt.finished = c.clock()
+	c.mu.Unlock()
+	close(attempt.done)
+	return attempt.receipt, attempt.err
+}
+
+func (c *CheckoutFacade) clock() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
+}
+
+// sweep forgets every expired order, so old keys don't pile up. Runs at most once per
+// keyTTL. Must hold c.mu.
+func (c *CheckoutFacade) sweep(now time.Time) {
+	for key, a := range c.orders {
+		if a.expired(now) {
+			delete(c.orders, key)
+		}
+	}
+	c.lastSweep = now
+}
+
+func (c *CheckoutFacade) placeOrder(orderID string, req OrderRequest) (*Receipt, error) {
+	r := &Receipt{OrderID: orderID}
+	var reservation Reservation
+	err := runSteps(context.Background(), nil, "place order", []Step{
+		{
+			Name: "inventory.Reserve",
+			Do: func() (err error) {
+				reservation, err = c.inventory.Reserve(req.Items)
+				r.Subtotal = reservation.Subtotal
+				return err
+			},
+			Undo: func() error { return c.inventory.Release(reservation.ID) },
+		},
+		{
+			Name: "shipping.Quote",
+			Do: func() (err error) {
+				r.Shipping, err = c.shipping.Quote(req.Country, req.Items)
+				return err
+			},
+		},
+		{
+			Name: "payments.Charge",
+			Do: func() (err error) {
+				r.Total = r.Subtotal + r.Shipping
+				r.ChargeID, err = c.payments.Charge(req.IdempotencyKey, req.Card, r.Total)
+				return err
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.mailer.SendConfirmation(req.Email, *r); err != nil {
+		r.EmailErr = err
+	} else {
+		r.EmailSent = true
+	}
+	return r, nil
+}
+
+// -- In-Memory Fakes --
+
+// MemoryInventory is a stock room with prices.
+type MemoryInventory struct {
+	mu           sync.Mutex
+	prices       map[string]Cents
+	stock        map[string]int
+	reservations map[string][]LineItem
+	nextID       int
+}
+
+func NewMemoryInventory() *MemoryInventory {
+	return &MemoryInventory{prices: map[string]Cents{}, stock: map[string]int{}, reservations: map[string][]LineItem{}}
+}
+
+// Add puts quantity more of sku on the shelf at price each.
+func (m *MemoryInventory) Add(sku string, price Cents, quantity int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prices[sku] = price
+	m.stock[sku] += quantity
+}
+
+func (m *MemoryInventory) Stock(sku string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stock[sku]
+}
+
+// Reserve holds every item or none of them.
+func (m *MemoryInventory) Reserve(items []LineItem) (Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	want := map[string]int{}
+	var subtotal Cents
+	for _, it := range items {
+		price, ok := m.prices[it.SKU]
+		if !ok {
+			return Reservation{}, fmt.Errorf("%s: %w", it.SKU, ErrUnknownItem)
+		}
+		if it.Quantity <= 0 {
+			return Reservation{}, fmt.Errorf("%s x%d: %w", it.SKU, it.Quantity, ErrBadQuantity)
+		}
+		want[it.SKU] += it.Quantity
+		subtotal += price * Cents(it.Quantity)
+	}
+	for sku, n := range want {
+		if m.stock[sku] < n {
+			return Reservation{}, fmt.Errorf("%s: want %d, have %d: %w", sku, n, m.stock[sku], ErrOutOfStock)
+		}
+	}
+	for sku, n := range want {
+		m.stock[sku] -= n
+	}
+	m.nextID++
+	id := fmt.Sprintf("res-%d", m.nextID)
+	m.reservations[id] = slices.Clone(items)
+	return Reservation{ID: id, Subtotal: subtotal}, nil
+}
+
+func (m *MemoryInventory) Release(reservationID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items, ok := m.reservations[reservationID]
+	if !ok {
+		return fmt.Errorf("no reservation %q", reservationID)
+	}
+	for _, it := range items {
+		m.stock[it.SKU] += it.Quantity
+	}
+	delete(m.reservations, reservationID)
+	return nil
+}
+
+// FlatRateShipping charges Base plus PerItem, but only to the listed countries.
+type FlatRateShipping struct {
+	Base, PerItem Cents
+	Countries     []string
+}
+
+func (s FlatRateShipping) Quote(country string, items []LineItem) (Cents, error) {
+	if !slices.Contains(s.Countries, country) {
+		return 0, fmt.Errorf("%s: %w", country, ErrNoShipping)
+	}
+	n := 0
+	for _, it := range items {
+		n += it.Quantity
+	}
+	return s.Base + s.PerItem*Cents(n), nil
+}
+
+// FakePayments remembers every charge by idempotency key. Cards in Declined always fail.
+type FakePayments struct {
+	Declined []string
+
+	mu      sync.Mutex
+	charges map[string]string // idempotency key -> charge ID
+	charged Cents
+}
+
+func (p *FakePayments) Charge(key, card string, amount Cents) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.charges[key]; ok {
+		return id, nil
+	}
+	if slices.Contains(p.Declined, card) {
+		return "", fmt.Errorf("card ending %s: %w", card[max(0, len(card)-4):], ErrCardDeclined)
+	}
+	if p.charges == nil {
+		p.charges = map[string]string{}
+	}
+	id := fmt.Sprintf("ch-%d", len(p.charges)+1)
+	p.charges[key] = id
+	p.charged += amount
+	return id, nil
+}
+
+// Total is every cent actually taken, to prove nobody paid twice.
+func (p *FakePayments) Total() Cents {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.charged
+}
+
+// FakeMailer keeps sent emails in an outbox. Set Down (or call SetDown once orders are
+// being placed) to make it fail.
+type FakeMailer struct {
+	Down bool
+
+	mu     sync.Mutex
+	outbox []string
+}
+
+func (m *FakeMailer) SetDown(down bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Down = down
+}
+
+func (m *FakeMailer) SendConfirmation(email string, r Receipt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Down {
+		return errors.New("mail server unreachable")
+	}
+	m.outbox = append(m.outbox, fmt.Sprintf("to %s: %s confirmed, %s charged", email, r.OrderID, r.Total))
+	return nil
+}
+
+func (m *FakeMailer) Sent() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.outbox)
+}
+
+// describe is a one-line summary of a PlaceOrder result, for the demo.
+func describe(r *Receipt, err error) string {
+	if err != nil {
+		return "FAILED: " + err.Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s + %s shipping = %s (%s)", r.OrderID, r.Subtotal, r.Shipping, r.Total, r.ChargeID)
+	if !r.EmailSent {
+		fmt.Fprintf(&b, ", but no email: %v", r.EmailErr)
+	}
+	return b.String()
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+type shop struct {
+	inventory *MemoryInventory
+	payments  *FakePayments
+	mailer    *FakeMailer
+	checkout  *CheckoutFacade
+}
+
+const declinedCard = "4000-0000-0000-0002"
+
+// newShop is the shop from the demo: 3 teddy bears at $19.99, 10 yo-yos at $4.50,
+// shipping $5 + $1 an item to the US and India.
+func newShop(inventory Inventory) *shop {
+	s := &shop{
+		inventory: NewMemoryInventory(),
+		payments:  &FakePayments{Declined: []string{declinedCard}},
+		mailer:    &FakeMailer{},
+	}
+	s.inventory.Add("teddy-bear", 1999, 3)
+	s.inventory.Add("yo-yo", 450, 10)
+	if inventory == nil {
+		inventory = s.inventory
+	}
+	s.checkout = NewCheckoutFacade(inventory, FlatRateShipping{Base: 500, PerItem: 100, Countries: []string{"US", "IN"}}, s.payments, s.mailer)
+	return s
+}
+
+func order(key string) OrderRequest {
+	return OrderRequest{
+		IdempotencyKey: key,
+		Email:          "kid@example.com",
+		Country:        "US",
+		Card:           "4242-4242-4242-4242",
+		Items:          []LineItem{{"teddy-bear", 1}, {"yo-yo", 2}},
+	}
+}
+
+// check compares what the shop has left with what it should have.
+func (s *shop) check(t *testing.T, teddies int, charged Cents, emails int) {
+	t.Helper()
+	if got := s.inventory.Stock("teddy-bear"); got != teddies {
+		t.Errorf("teddy bears on the shelf: %d, want %d", got, teddies)
+	}
+	if got := s.payments.Total(); got != charged {
+		t.Errorf("charged %s, want %s", got, charged)
+	}
+	if got := len(s.mailer.Sent()); got != emails {
+		t.Errorf("%d email(s) sent, want %d", got, emails)
+	}
+}
+
+func TestPlaceOrder(t *testing.T) {
+	s := newShop(nil)
+	r, err := s.checkout.PlaceOrder(order("cart-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Receipt{OrderID: "order-1", Subtotal: 2899, Shipping: 800, Total: 3699, ChargeID: "ch-1", EmailSent: true}
+	if *r != want {
+		t.Errorf("receipt = %+v, want %+v", *r, want)
+	}
+	s.check(t, 2, 3699, 1)
+}
+
+func TestPlaceOrderFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*OrderRequest)
+		want error
+	}{
+		{"out of stock", func(r *OrderRequest) { r.Items = []LineItem{{"teddy-bear", 5}} }, ErrOutOfStock},
+		{"unknown item", func(r *OrderRequest) { r.Items = []LineItem{{"kite", 1}} }, ErrUnknownItem},
+		{"no items", func(r *OrderRequest) { r.Items = nil }, ErrNoItems},
+		{"no teddies", func(r *OrderRequest) { r.Items = []LineItem{{"yo-yo", 1}, {"teddy-bear", 0}} }, ErrBadQuantity},
+		{"fewer than no yo-yos", func(r *OrderRequest) { r.Items = []LineItem{{"yo-yo", -1}} }, ErrBadQuantity},
+		{"no shipping", func(r *OrderRequest) { r.Country = "NZ" }, ErrNoShipping},
+		{"card declined", func(r *OrderRequest) { r.Card = declinedCard }, ErrCardDeclined},
+		{"no key", func(r *OrderRequest) { r.IdempotencyKey = "" }, ErrMissingKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newShop(nil)
+			req := order("cart-1")
+			tt.edit(&req)
+			r, err := s.checkout.PlaceOrder(req)
+			if r != nil || !errors.Is(err, tt.want) {
+				t.Fatalf("got %+v, %v; want %v", r, err, tt.want)
+			}
+			s.check(t, 3, 0, 0) // anything held was released, nothing charged
+		})
+	}
+}
+
+func TestRollbackReportsWhatWasReleased(t *testing.T) {
+	s := newShop(nil)
+	req := order("cart-1")
+	req.Card = declinedCard
+	_, err := s.checkout.PlaceOrder(req)
+	var rollback *RollbackError
+	if !errors.As(err, &rollback) || rollback.Step != "payments.Charge" || len(rollback.Compensated) != 1 || rollback.Compensated[0] != "inventory.Reserve" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestMailDownStillPlacesTheOrder(t *testing.T) {
+	s := newShop(nil)
+	s.mailer.SetDown(true)
+	r, err := s.checkout.PlaceOrder(order("cart-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.EmailSent || r.EmailErr == nil || r.ChargeID == "" {
+		t.Errorf("receipt = %+v; want a charge and an email error", *r)
+	}
+	s.check(t, 2, 3699, 0)
+}
+
+func TestSameKey(t *testing.T) {
+	s := newShop(nil)
+	first, err := s.checkout.PlaceOrder(order("cart-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.checkout.PlaceOrder(order("cart-1"))
+	if err != nil || again != first {
+		t.Errorf("retry got %+v, %v; want the first receipt", again, err)
+	}
+
+	changed := order("cart-1")
+	changed.Items = []LineItem{{"yo-yo", 1}}
+	if r, err := s.checkout.PlaceOrder(changed); r != nil || !errors.Is(err, ErrKeyReused) {
+		t.Errorf("different cart got %+v, %v; want ErrKeyReused", r, err)
+	}
+	s.check(t, 2, 3699, 1)
+}
+
+// Fields that run into each other still make a different order.
+func TestSameKeyDifferentFields(t *testing.T) {
+	s := newShop(nil)
+	first := order("cart-1")
+	first.Email, first.Country = "kid@example.com|US", ""
+	second := order("cart-1")
+	second.Email, second.Country = "kid@example.com", "US|"
+	s.checkout.PlaceOrder(first)
+	if r, err := s.checkout.PlaceOrder(second); r != nil || !errors.Is(err, ErrKeyReused) {
+		t.Errorf("got %+v, %v; want ErrKeyReused", r, err)
+	}
+}
+
+func TestKeysExpire(t *testing.T) {
+	s := newShop(nil)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.checkout.now = func() time.Time { return now }
+
+	first, err := s.checkout.PlaceOrder(order("cart-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(keyTTL - time.Second)
+	if again, _ := s.checkout.PlaceOrder(order("cart-1")); again != first {
+		t.Errorf("a second before the key expires: got %+v, want the first receipt", again)
+	}
+
+	now = now.Add(time.Second)
+	again, err := s.checkout.PlaceOrder(order("cart-1"))
+	if err != nil || again == first {
+		t.Errorf("after the key expired: got %+v, %v; want a new order", again, err)
+	}
+	s.check(t, 1, 3699, 2) // FakePayments never forgets a key, so the second order is free
+
+	now = now.Add(keyTTL)
+	if _, err := s.checkout.PlaceOrder(order("cart-2")); err != nil {
+		t.Fatal(err)
+	}
+	s.checkout.mu.Lock()
+	defer s.checkout.mu.Unlock()
+	if n := len(s.checkout.orders); n != 1 {
+		t.Errorf("%d orders remembered, want only cart-2", n)
+	}
+}
+
+func TestSameKeyAfterFailureTriesAgain(t *testing.T) {
+	s := newShop(nil)
+	req := order("cart-1")
+	req.Items = []LineItem{{"teddy-bear", 4}}
+	if _, err := s.checkout.PlaceOrder(req); !errors.Is(err, ErrOutOfStock) {
+		t.Fatalf("got %v, want ErrOutOfStock", err)
+	}
+	s.inventory.Add("teddy-bear", 1999, 1)
+	if _, err := s.checkout.PlaceOrder(req); err != nil {
+		t.Fatalf("retry after restocking: %v", err)
+	}
+	s.check(t, 0, 4*1999+900, 1)
+}
+
+// slowInventory holds the first Reserve until release is closed.
+type slowInventory struct {
+	*MemoryInventory
+	entered, release chan struct{}
+
+	mu       sync.Mutex
+	reserves int
+}
+
+func (s *slowInventory) Reserve(items []LineItem) (Reservation, error) {
+	s.mu.Lock()
+	s.reserves++
+	first := s.reserves == 1
+	s.mu.Unlock()
+	if first {
+		close(s.entered)
+		<-s.release
+	}
+	return s.MemoryInventory.Reserve(items)
+}
+
+func TestConcurrentDoubleClick(t *testing.T) {
+	slow := &slowInventory{entered: make(chan struct{}), release: make(chan struct{})}
+	s := newShop(slow)
+	slow.MemoryInventory = s.inventory
+
+	receipts := make([]*Receipt, 5)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		receipts[0], _ = s.checkout.PlaceOrder(order("cart-1"))
+	}()
+	<-slow.entered // the first click is halfway through
+	for i := 1; i < len(receipts); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			receipts[i], _ = s.checkout.PlaceOrder(order("cart-1"))
+		}()
+	}
+	close(slow.release)
+	wg.Wait()
+
+	for i, r := range receipts {
+		if r == nil || r != receipts[0] {
+			t.Errorf("click %d got %+v; want the first click's receipt", i, r)
+		}
+	}
+	if slow.reserves != 1 {
+		t.Errorf("Reserve called %d times, want 1", slow.reserves)
+	}
+	s.check(t, 2, 3699, 1)
+}
+
+func TestCentsString(t *testing.T) {
+	for _, tt := range []struct {
+		c    Cents
+		want string
+	}{
+		{0, "$0.00"},
+		{5, "$0.05"},
+		{1999, "$19.99"},
+		{-5, "-$0.05"},
+		{-150, "-$1.50"},
+		{math.MinInt64, "-$92233720368547758.08"},
+		{math.MaxInt64, "$92233720368547758.07"},
+	} {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("Cents(%d) = %q, want %q", int64(tt.c), got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,15 @@
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
 
 // Facade Pattern
 //
@@ -25,23 +34,77 @@ import "fmt"
 // The user sees ONE simple action, but the Facade handles the complex mess behind it.
 
 // -- Complex Subsystem Parts --
+// Every part can fail. FailOn lists the methods that should fail, so the demo can break things.
 
-type TV struct{}
+type TV struct{ FailOn []string }
 
-func (t *TV) On()  { fmt.Println("TV: Turning ON") }
-func (t *TV) Off() { fmt.Println("TV: Turning OFF") }
+func (t *TV) On() error {
+	if err := fault("tv", "On", t.FailOn); err != nil {
+		return err
+	}
+	fmt.Println("TV: Turning ON")
+	return nil
+}
+
+func (t *TV) Off() error {
+	if err := fault("tv", "Off", t.FailOn); err != nil {
+		return err
+	}
+	fmt.Println("TV: Turning OFF")
+	return nil
+}
+
+type SoundSystem struct{ FailOn []string }
+
+func (s *SoundSystem) On() error {
+	if err := fault("sound", "On", s.FailOn); err != nil {
+		return err
+	}
+	fmt.Println("Sound: Turning ON")
+	return nil
+}
 
-type SoundSystem struct{}
+func (s *SoundSystem) SetVolume(vol int) error {
+	if err := fault("sound", "SetVolume", s.FailOn); err != nil {
+		return err
+	}
+	fmt.Printf("Sound: Volume set to %d\n", vol)
+	return nil
+}
 
-func (s *SoundSystem) On()               { fmt.Println("Sound: Turning ON") }
-func (s *SoundSystem) SetVolume(vol int) { fmt.Printf("Sound: Volume set to %d\n", vol) }
-func (s *SoundSystem) Off()              { fmt.Println("Sound: Turning OFF") }
+func (s *SoundSystem) Off() error {
+	if err := fault("sound", "Off", s.FailOn); err != nil {
+		return err
+	}
+	fmt.Println("Sound: Turning OFF")
+	return nil
+}
 
-type GameConsole struct{}
+type GameConsole struct{ FailOn []string }
 
-func (g *GameConsole) On()        { fmt.Println("Console: Turning ON") }
-func (g *GameConsole) StartGame() { fmt.Println("Console: Starting the game...") }
-func (g *GameConsole) Off()       { fmt.Println("Console: Turning OFF") }
+func (g *GameConsole) On() error {
+	if err := fault("console", "On", g.FailOn); err != nil {
+		return err
+	}
+	fmt.Println("Console: Turning ON")
+	return nil
+}
+
+func (g *GameConsole) StartGame() error {
+	if err := fault("console", "StartGame", g.FailOn); err != nil {
+		return err
+	}
+	fmt.Println("Console: Starting the game...")
+	return nil
+}
+
+func (g *GameConsole) Off() error {
+	if err := fault("console", "Off", g.FailOn); err != nil {
+		return err
+	}
+	fmt.Println("Console: Turning OFF")
+	return nil
+}
 
 // -- The Facade --
 
@@ -49,6 +112,8 @@ type GameFacade struct {
 	tv      *TV
 	sound   *SoundSystem
 	console *GameConsole
+	running *StartupGraph // set by QuickPlay
+	tracer  Tracer        // optional, see trace.go
 }
 
 func NewGameFacade() *GameFacade {
@@ -59,24 +124,99 @@ func NewGameFacade() *GameFacade {
 	}
 }
 
-// PlayGame is the simple button
-func (g *GameFacade) PlayGame() {
+// SetTracer makes every button report each step it takes to t. nil turns tracing off.
+func (g *GameFacade) SetTracer(t Tracer) {
+	g.tracer = t
+}
+
+// PlayGame is the simple button. If any step fails, everything it already switched on
+// is switched back off, and the error says what failed and what was undone.
+func (g *GameFacade) PlayGame() (err error) {
 	fmt.Println("\n>>> Master Button: PLAY GAME <<<")
-	g.tv.On()
-	g.sound.On()
-	g.sound.SetVolume(50)
-	g.console.On()
-	g.console.StartGame()
+	ctx, span := startSpan(context.Background(), g.tracer, "PlayGame")
+	defer func() { span.End(err) }()
+	err = runSteps(ctx, g.tracer, "play game", []Step{
+		{Name: "tv.On", Do: g.tv.On, Undo: g.tv.Off},
+		{Name: "sound.On", Do: g.sound.On, Undo: g.sound.Off},
+		{Name: "sound.SetVolume", Do: func() error { return g.sound.SetVolume(50) }},
+		{Name: "console.On", Do: g.console.On, Undo: g.console.Off},
+		{Name: "console.StartGame", Do: g.console.StartGame},
+	})
+	if err != nil {
+		return err
+	}
 	fmt.Println(">>> Ready to play! <<<")
+	return nil
 }
 
-// StopGame is another simple button
-func (g *GameFacade) StopGame() {
+// StopGame is another simple button. It tries to switch off everything,
+// even if one device refuses, and reports every device that did.
+func (g *GameFacade) StopGame() (err error) {
 	fmt.Println("\n>>> Master Button: STOP GAME <<<")
-	g.console.Off()
-	g.sound.Off()
-	g.tv.Off()
+	ctx, span := startSpan(context.Background(), g.tracer, "StopGame")
+	defer func() { span.End(err) }()
+	err = errors.Join(
+		traced(ctx, g.tracer, "console.Off", g.console.Off),
+		traced(ctx, g.tracer, "sound.Off", g.sound.Off),
+		traced(ctx, g.tracer, "tv.Off", g.tv.Off),
+	)
+	if err != nil {
+		return fmt.Errorf("stop game: %w", err)
+	}
 	fmt.Println(">>> Goodnight! <<<")
+	return nil
+}
+
+// startupTasks is PlayGame written as a graph: the TV, speakers and console don't need
+// each other, only the game needs all three.
+func (g *GameFacade) startupTasks() []Task {
+	wrap := func(fn func() error) func(context.Context) error {
+		return func(context.Context) error { return fn() }
+	}
+	return []Task{
+		{Name: "tv.On", Start: wrap(g.tv.On), Stop: wrap(g.tv.Off), Timeout: 2 * time.Second},
+		{Name: "sound.On", Start: wrap(g.sound.On), Stop: wrap(g.sound.Off), Timeout: time.Second},
+		{Name: "sound.SetVolume", DependsOn: []string{"sound.On"}, Start: wrap(func() error { return g.sound.SetVolume(50) })},
+		{Name: "console.On", Start: wrap(g.console.On), Stop: wrap(g.console.Off), Timeout: 5 * time.Second},
+		{
+			Name:      "console.StartGame",
+			DependsOn: []string{"tv.On", "sound.SetVolume", "console.On"},
+			Start:     wrap(g.console.StartGame),
+			Timeout:   10 * time.Second,
+		},
+	}
+}
+
+// QuickPlay is PlayGame, but everything that can switch on at the same time does.
+func (g *GameFacade) QuickPlay(ctx context.Context) (err error) {
+	fmt.Println("\n>>> Master Button: QUICK PLAY <<<")
+	ctx, span := startSpan(ctx, g.tracer, "QuickPlay")
+	defer func() { span.End(err) }()
+	graph, err := NewStartupGraph(g.startupTasks()...)
+	if err != nil {
+		return err
+	}
+	graph.Tracer = g.tracer
+	if err := graph.Start(ctx); err != nil {
+		return err
+	}
+	g.running = graph
+	fmt.Println(">>> Ready to play! <<<")
+	return nil
+}
+
+// QuickStop switches off whatever QuickPlay switched on, in reverse: nothing goes off
+// while something that needs it is still on.
+func (g *GameFacade) QuickStop(ctx context.Context) (err error) {
+	if g.running == nil {
+		return nil
+	}
+	fmt.Println("\n>>> Master Button: QUICK STOP <<<")
+	ctx, span := startSpan(ctx, g.tracer, "QuickStop")
+	defer func() { span.End(err) }()
+	err = g.running.Stop(ctx)
+	g.running = nil
+	return err
 }
 
 func main() {
@@ -85,8 +225,174 @@ func main() {
 	facade := NewGameFacade()
 
 	// Start everything with one call
-	facade.PlayGame()
+	if err := facade.PlayGame(); err != nil {
+		fmt.Println("Error:", err)
+	}
 
 	// Stop everything with one call
-	facade.StopGame()
+	if err := facade.StopGame(); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	// The game disc is scratched: everything that was switched on goes back off, newest first.
+	fmt.Println("\n--- Facade Pattern: When a Step Fails ---")
+	broken := &GameFacade{tv: &TV{}, sound: &SoundSystem{}, console: &GameConsole{FailOn: []string{"StartGame"}}}
+	err := broken.PlayGame()
+	fmt.Println("Error:", err)
+	var rollback *RollbackError
+	if errors.As(err, &rollback) {
+		fmt.Printf("Failed at %s, undid %v\n", rollback.Step, rollback.Compensated)
+	}
+
+	// Worse: the speakers won't switch off either. The TV still gets switched off.
+	worse := &GameFacade{tv: &TV{}, sound: &SoundSystem{FailOn: []string{"Off"}}, console: &GameConsole{FailOn: []string{"On"}}}
+	err = worse.PlayGame()
+	fmt.Println("Error:", err)
+	fmt.Println("Is a device fault:", errors.Is(err, ErrDeviceFault))
+
+	// The checkout from the top of this file: four subsystems, one button.
+	fmt.Println("\n--- Facade Pattern: One-Click Checkout ---")
+	inventory := NewMemoryInventory()
+	inventory.Add("teddy-bear", 1999, 3)
+	inventory.Add("yo-yo", 450, 10)
+	payments := &FakePayments{Declined: []string{"4000-0000-0000-0002"}}
+	mailer := &FakeMailer{}
+	checkout := NewCheckoutFacade(inventory, FlatRateShipping{Base: 500, PerItem: 100, Countries: []string{"US", "IN"}}, payments, mailer)
+
+	order := OrderRequest{
+		IdempotencyKey: "cart-1",
+		Email:          "kid@example.com",
+		Country:        "US",
+		Card:           "4242-4242-4242-4242",
+		Items:          []LineItem{{"teddy-bear", 1}, {"yo-yo", 2}},
+	}
+	scenario := func(name string, req OrderRequest) {
+		fmt.Printf("%-28s %s\n", name+":", describe(checkout.PlaceOrder(req)))
+	}
+
+	scenario("Happy path", order)
+	scenario("Double click (same key)", order)
+	fmt.Printf("  charged %s in total, %d email(s) sent\n", payments.Total(), len(mailer.Sent()))
+
+	changed := order
+	changed.Items = []LineItem{{"yo-yo", 1}}
+	scenario("Same key, different cart", changed)
+
+	tooMany := OrderRequest{IdempotencyKey: "cart-2", Email: "kid@example.com", Country: "US", Card: order.Card,
+		Items: []LineItem{{"teddy-bear", 5}}}
+	scenario("Out of stock", tooMany)
+
+	abroad := order
+	abroad.IdempotencyKey, abroad.Country = "cart-3", "NZ"
+	scenario("No shipping", abroad)
+	fmt.Printf("  teddy bears on the shelf: %d (nothing left held)\n", inventory.Stock("teddy-bear"))
+
+	declined := order
+	declined.IdempotencyKey, declined.Card = "cart-4", "4000-0000-0000-0002"
+	scenario("Card declined", declined)
+	fmt.Printf("  teddy bears on the shelf: %d (released again)\n", inventory.Stock("teddy-bear"))
+
+	mailer.SetDown(true)
+	late := order
+	late.IdempotencyKey = "cart-5"
+	scenario("Mail server down", late)
+	fmt.Printf("  charged %s in total, teddy bears left: %d\n", payments.Total(), inventory.Stock("teddy-bear"))
+
+	// An impatient customer clicks five times at once. One order, one charge.
+	mailer.SetDown(false)
+	impatient := order
+	impatient.IdempotencyKey, impatient.Items = "cart-6", []LineItem{{"yo-yo", 1}}
+	var wg sync.WaitGroup
+	ids := make([]string, 5)
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, err := checkout.PlaceOrder(impatient); err == nil {
+				ids[i] = r.OrderID
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("%-28s %v, charged %s in total\n", "Five clicks at once:", ids, payments.Total())
+
+	// Independent steps at the same time; the game waits for all three devices.
+	fmt.Println("\n--- Facade Pattern: Starting in Parallel ---")
+	ctx := context.Background()
+	quick := NewGameFacade()
+	if err := quick.QuickPlay(ctx); err != nil {
+		fmt.Println("Error:", err)
+	}
+	if err := quick.QuickStop(ctx); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	// The same idea booting services. The cache hangs, so its time limit kicks in,
+	// and everything that already started is shut down again, newest first.
+	service := func(name string, took time.Duration) func(context.Context) error {
+		return func(ctx context.Context) error {
+			select {
+			case <-time.After(took):
+				fmt.Printf("  %s is up\n", name)
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	stop := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			fmt.Printf("  %s is down\n", name)
+			return nil
+		}
+	}
+	bootTasks := []Task{
+		{Name: "config", Start: service("config", 10*time.Millisecond), Stop: stop("config")},
+		{Name: "database", DependsOn: []string{"config"}, Start: service("database", 30*time.Millisecond), Stop: stop("database")},
+		{Name: "cache", DependsOn: []string{"config"}, Start: service("cache", time.Second), Stop: stop("cache"), Timeout: 100 * time.Millisecond},
+		{Name: "metrics", Start: service("metrics", 20*time.Millisecond), Stop: stop("metrics")},
+		{Name: "api", DependsOn: []string{"database", "cache"}, Start: service("api", 10*time.Millisecond), Stop: stop("api")},
+	}
+	boot, err := NewStartupGraph(bootTasks...)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Booting services:")
+	if err := boot.Start(ctx); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	// A loop in the dependencies is caught before anything starts.
+	_, err = NewStartupGraph(
+		Task{Name: "auth", DependsOn: []string{"users"}},
+		Task{Name: "users", DependsOn: []string{"database"}},
+		Task{Name: "database", DependsOn: []string{"auth"}},
+	)
+	fmt.Println("Error:", err)
+
+	// Looking inside: which steps ran, how long they took, and where it went wrong.
+	fmt.Println("\n--- Facade Pattern: Tracing the Steps ---")
+	tree := NewTreeTracer()
+	broken.SetTracer(tree)
+	broken.PlayGame()
+	fmt.Println()
+	tree.Print(os.Stdout)
+
+	// The same for QuickPlay, saved for a trace viewer (chrome://tracing or ui.perfetto.dev).
+	viewer := NewTraceEventTracer()
+	quick.SetTracer(viewer)
+	quick.QuickPlay(ctx)
+	quick.QuickStop(ctx)
+	var trace bytes.Buffer
+	if err := viewer.WriteJSON(&trace); err != nil {
+		fmt.Println("Error:", err)
+	}
+	fmt.Printf("\nTrace: %d spans, %d bytes of JSON, starting with:\n%s\n",
+		len(viewer.Spans()), trace.Len(), firstLines(trace.String(), 12))
+}
+
+func firstLines(s string, n int) string {
+	lines := strings.SplitN(s, "\n", n+1)
+	return strings.Join(lines[:min(n, len(lines))], "\n")
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Undoing Half-Finished Work
+//
+// If the game won't start, leaving the TV and speakers blasting isn't "simple" anymore.
+// Every step the facade takes can say how to take it back. When a step fails, the steps
+// that already worked are undone in reverse order (last on, first off), and the caller
+// gets one error that tells the whole story.
+
+// ErrDeviceFault is what a device says when it misbehaves.
+var ErrDeviceFault = errors.New("device fault")
+
+// fault returns an error if method is one of the ones a device was told to fail on.
+func fault(device, method string, failOn []string) error {
+	if slices.Contains(failOn, method) {
+		return fmt.Errorf("%s.%s: %w", device, method, ErrDeviceFault)
+	}
+	return nil
+}
+
+// Step is one subsystem call the facade makes. Undo may be nil if there's nothing to take back.
+type Step struct {
+	Name string
+	Do   func() error
+	Undo func() error
+}
+
+// RollbackError reports a failed step and what was done to clean up after it.
+type RollbackError struct {
+	Op          string   // e.g. "play game"
+	Step        string   // the step that failed; several, comma-separated, if they failed at once
+	Err         error    // why it failed
+	Compensated []string // steps that were undone, in the order they were undone
+	UndoErr     error    // undo steps that failed too, joined; nil if cleanup went fine
+}
+
+func (e *RollbackError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: step %s failed: %v", e.Op, e.Step, e.Err)
+	if len(e.Compensated) > 0 {
+		fmt.Fprintf(&b, "; rolled back: %s", strings.Join(e.Compensated, ", "))
+	} else if e.UndoErr == nil {
+		b.WriteString("; nothing to roll back")
+	}
+	if e.UndoErr != nil {
+		fmt.Fprintf(&b, "; rollback failed: %v", e.UndoErr)
+	}
+	return b.String()
+}
+
+// Unwrap lets errors.Is find both the failure and any cleanup failures.
+func (e *RollbackError) Unwrap() []error {
+	if e.UndoErr == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.UndoErr}
+}
+
+// runSteps runs steps in order. If one fails, the finished ones are undone in reverse.
+// A failed undo doesn't stop the others; every device still gets its chance to switch off.
+// Every step, and the rollback, gets a span if tracer isn't nil.
+func runSteps(ctx context.Context, tracer Tracer, op string, steps []Step) error {
+	for i, s := range steps {
+		err := traced(ctx, tracer, s.Name, s.Do)
+		if err == nil {
+			continue
+		}
+		rollback := &RollbackError{Op: op, Step: s.Name, Err: err}
+		ctx, span := startSpan(ctx, tracer, "rollback")
+		var undoErrs []error
+		for _, done := range slices.Backward(steps[:i]) {
+			if done.Undo == nil {
+				continue
+			}
+			if err := traced(ctx, tracer, "undo "+done.Name, done.Undo); err != nil {
+				undoErrs = append(undoErrs, fmt.Errorf("undo %s: %w", done.Name, err))
+				continue
+			}
+			rollback.Compensated = append(rollback.Compensated, done.Name)
+		}
+		rollback.UndoErr = errors.Join(undoErrs...)
+		span.End(rollback.UndoErr)
+		return rollback
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// undone lists the undo steps tracer saw, in the order they ran.
+func undone(tracer *TreeTracer) []string {
+	var names []string
+	for _, s := range tracer.Spans() {
+		if name, ok := strings.CutPrefix(s.Name, "undo "); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func TestPlayGameRollsBackEachStep(t *testing.T) {
+	tests := []struct {
+		step               string
+		tv, sound, console []string // FailOn
+		undone             []string // every undo that ran, in order
+		compensated        []string // the ones that worked
+		undoFailed         string
+	}{
+		{step: "tv.On", tv: []string{"On"}},
+		{step: "sound.On", sound: []string{"On"}, undone: []string{"tv.On"}, compensated: []string{"tv.On"}},
+		{
+			step: "sound.SetVolume", sound: []string{"SetVolume"},
+			undone: []string{"sound.On", "tv.On"}, compensated: []string{"sound.On", "tv.On"},
+		},
+		{
+			step: "console.On", console: []string{"On"},
+			undone: []string{"sound.On", "tv.On"}, compensated: []string{"sound.On", "tv.On"},
+		},
+		{
+			step: "console.StartGame", console: []string{"StartGame"},
+			undone: []string{"console.On", "sound.On", "tv.On"}, compensated: []string{"console.On", "sound.On", "tv.On"},
+		},
+		// The speakers won't go off, and the TV still does.
+		{
+			step: "console.StartGame", sound: []string{"Off"}, console: []string{"StartGame"},
+			undone: []string{"console.On", "sound.On", "tv.On"}, compensated: []string{"console.On", "tv.On"}, undoFailed: "sound.On",
+		},
+	}
+	for _, tt := range tests {
+		tracer := NewTreeTracer()
+		g := &GameFacade{tv: &TV{FailOn: tt.tv}, sound: &SoundSystem{FailOn: tt.sound}, console: &GameConsole{FailOn: tt.console}}
+		g.SetTracer(tracer)
+		err := g.PlayGame()
+
+		var rollback *RollbackError
+		if !errors.As(err, &rollback) {
+			t.Fatalf("%s: got %v, want a *RollbackError", tt.step, err)
+		}
+		if !errors.Is(err, ErrDeviceFault) {
+			t.Errorf("%s: %v is not a device fault", tt.step, err)
+		}
+		if rollback.Step != tt.step {
+			t.Errorf("failed at %s, want %s", rollback.Step, tt.step)
+		}
+		if !slices.Equal(rollback.Compensated, tt.compensated) {
+			t.Errorf("%s: Compensated %v, want %v", tt.step, rollback.Compensated, tt.compensated)
+		}
+
+		// Every finished step with an Undo gets one, newest first, even after one fails.
+		if got := undone(tracer); !slices.Equal(got, tt.undone) {
+			t.Errorf("%s: undid %v, want %v", tt.step, got, tt.undone)
+		}
+
+		if tt.undoFailed == "" {
+			if rollback.UndoErr != nil {
+				t.Errorf("%s: UndoErr %v, want nil", tt.step, rollback.UndoErr)
+			}
+			if n := len(rollback.Unwrap()); n != 1 {
+				t.Errorf("%s: Unwrap returned %d errors, want just the failure", tt.step, n)
+			}
+			continue
+		}
+		if rollback.UndoErr == nil || !strings.Contains(rollback.UndoErr.Error(), "undo "+tt.undoFailed) {
+			t.Errorf("%s: UndoErr %v doesn't mention undo %s", tt.step, rollback.UndoErr, tt.undoFailed)
+		}
+		if !errors.Is(rollback.UndoErr, ErrDeviceFault) {
+			t.Errorf("%s: UndoErr %v is not a device fault", tt.step, rollback.UndoErr)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Starting Things in the Right Order, at the Same Time
+//
+// PlayGame presses one button after another, but the TV doesn't have to wait for the
+// speakers. A StartupGraph is told what each task needs first, then starts everything
+// it can at once: as soon as all of a task's needs are running, it starts too.
+// - A loop in the needs ("A needs B, B needs A") is caught before anything starts.
+// - Every task can have its own time limit.
+// - If a task fails, nothing new starts, and what already started is stopped again.
+// - Stop switches things off in reverse: nothing is stopped while something that needs it still runs.
+
+// Task is one thing to start, and how to stop it again.
+// Start and Stop should give up when their ctx is done. If Start runs over its Timeout
+// anyway, the task counts as failed; should it still succeed later, Stop is called on it
+// right away, so nothing is left running that the graph no longer knows about.
+type Task struct {
+	Name      string
+	DependsOn []string
+	Timeout   time.Duration // limit for Start and for Stop; 0 means none
+	Start     func(ctx context.Context) error
+	Stop      func(ctx context.Context) error // may be nil
+}
+
+// CycleError is a loop in DependsOn. [a b c a] means a needs b, b needs c, and c needs a.
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Path, " -> ")
+}
+
+type StartupGraph struct {
+	tasks      map[string]*Task
+	order      []string            // as given, so everything is repeatable
+	dependents map[string][]string // task -> tasks that need it
+
+	Tracer Tracer // optional: a span for every start and stop
+
+	mu      sync.Mutex
+	started []string // in the order they finished starting, which is always a topological order
+}
+
+// NewStartupGraph checks the tasks: names are unique, every dependency exists, no cycles.
+func NewStartupGraph(tasks ...Task) (*StartupGraph, error) {
+	g := &StartupGraph{tasks: map[string]*Task{}, dependents: map[string][]string{}}
+	for i := range tasks {
+		t := &tasks[i]
+		if _, dup := g.tasks[t.Name]; dup {
+			return nil, fmt.Errorf("task %q declared twice", t.Name)
+		}
+		g.tasks[t.Name] = t
+		g.order = append(g.order, t.Name)
+	}
+	for _, name := range g.order {
+		for _, dep := range g.tasks[name].DependsOn {
+			if _, ok := g.tasks[dep]; !ok {
+				return nil, fmt.Errorf("task %q depends on unknown task %q", name, dep)
+			}
+			g.dependents[dep] = append(g.dependents[dep], name)
+		}
+	}
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, &CycleError{Path: cycle}
+	}
+	return g, nil
+}
+
+// findCycle is a depth-first search that remembers the path it's on.
+// Meeting a task that's already on the path means we went in a circle.
+func (g *StartupGraph) findCycle() []string {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case onPath:
+			start := slices.Index(path, name)
+			return append(slices.Clone(path[start:]), name)
+		case done:
+			return nil
+		}
+		state[name] = onPath
+		path = append(path, name)
+		for _, dep := range g.tasks[name].DependsOn {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, name := range g.order {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// Start starts every task, each as soon as its dependencies are running.
+// On failure it stops whatever did start (newest first) and returns a *RollbackError.
+func (g *StartupGraph) Start(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result)
+	launch := func(name string) {
+		go func() {
+			t := g.tasks[name]
+			var late func()
+			if t.Stop != nil {
+				// Stopping a late starter has no one to report to; its span shows how it went.
+				late = func() { g.call(context.WithoutCancel(ctx), name, "stop", t.Timeout, t.Stop, nil) }
+			}
+			results <- result{name, g.call(runCtx, name, "start", t.Timeout, t.Start, late)}
+		}()
+	}
+
+	waitingOn := map[string]int{}
+	running := 0
+	for _, name := range g.order {
+		waitingOn[name] = len(g.tasks[name].DependsOn)
+		if waitingOn[name] == 0 {
+			launch(name)
+			running++
+		}
+	}
+
+	var failed []string
+	var errs []error
+	for running > 0 {
+		r := <-results
+		running--
+		if r.err != nil {
+			if len(failed) > 0 && ctx.Err() == nil && errors.Is(r.err, context.Canceled) {
+				continue // we cancelled it because of an earlier failure; that's not news
+			}
+			failed = append(failed, r.name)
+			errs = append(errs, r.err)
+			cancel() // tell the others still starting to give up
+			continue
+		}
+		g.mu.Lock()
+		g.started = append(g.started, r.name)
+		g.mu.Unlock()
+		if len(failed) > 0 {
+			continue // something failed: don't start anything new
+		}
+		for _, next := range g.dependents[r.name] {
+			if waitingOn[next]--; waitingOn[next] == 0 {
+				launch(next)
+				running++
+			}
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	// Clean up even though runCtx is cancelled, but still honor ctx's values.
+	stopped, stopErr := g.stopAll(context.WithoutCancel(ctx))
+	return &RollbackError{
+		Op:          "start",
+		Step:        strings.Join(failed, ", "),
+		Err:         errors.Join(errs...),
+		Compensated: stopped,
+		UndoErr:     stopErr,
+	}
+}
+
+// Stop stops every task that was started, in reverse topological order.
+// A task that fails to stop doesn't keep the others running.
+func (g *StartupGraph) Stop(ctx context.Context) error {
+	_, err := g.stopAll(ctx)
+	return err
+}
+
+func (g *StartupGraph) stopAll(ctx context.Context) (stopped []string, err error) {
+	g.mu.Lock()
+	started := g.started
+	g.started = nil
+	g.mu.Unlock()
+
+	var errs []error
+	for _, name := range slices.Backward(started) {
+		t := g.tasks[name]
+		if t.Stop == nil {
+			continue
+		}
+		if err := g.call(ctx, name, "stop", t.Timeout, t.Stop, nil); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		stopped = append(stopped, name)
+	}
+	return stopped, errors.Join(errs...)
+}
+
+// call runs fn with the task's time limit. If fn ignores its context and runs over,
+// we stop waiting for it, so one stuck task can't hang the whole startup. If it then
+// succeeds after all, late (when not nil) runs to undo it.
+func (g *StartupGraph) call(ctx context.Context, name, what string, timeout time.Duration, fn func(context.Context) error, late func()) (err error) {
+	ctx, span := startSpan(ctx, g.Tracer, what+" "+name)
+	defer func() { span.End(err) }()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		select {
+		case err = <-done: // finished just in time after all
+		default:
+			err = ctx.Err()
+			if late != nil {
+				go func() {
+					if <-done == nil {
+						late()
+					}
+				}()
+			}
+		}
+	}
+	switch {
+	case err == nil:
+		return nil
+	case timeout > 0 && errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		return fmt.Errorf("%s %s: took longer than %v: %w", what, name, timeout, err)
+	}
+	return fmt.Errorf("%s %s: %w", what, name, err)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// events keeps what the tasks did, in order, e.g. "start db", "stop db".
+type events struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (e *events) add(s string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.log = append(e.log, s)
+}
+
+func (e *events) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.log)
+}
+
+// task starts and stops instantly, writing both down.
+func (e *events) task(name string, deps ...string) Task {
+	return Task{
+		Name:      name,
+		DependsOn: deps,
+		Start:     func(context.Context) error { e.add("start " + name); return nil },
+		Stop:      func(context.Context) error { e.add("stop " + name); return nil },
+	}
+}
+
+func TestCycleError(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks []Task
+		want  []string
+	}{
+		{"itself", []Task{{Name: "a", DependsOn: []string{"a"}}}, []string{"a", "a"}},
+		{"two", []Task{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}}, []string{"a", "b", "a"}},
+		{"three, entered from outside", []Task{
+			{Name: "api", DependsOn: []string{"auth"}},
+			{Name: "auth", DependsOn: []string{"users"}},
+			{Name: "users", DependsOn: []string{"db"}},
+			{Name: "db", DependsOn: []string{"auth"}},
+		}, []string{"auth", "users", "db", "auth"}},
+	}
+	for _, tt := range tests {
+		_, err := NewStartupGraph(tt.tasks...)
+		var cycle *CycleError
+		if !errors.As(err, &cycle) || !slices.Equal(cycle.Path, tt.want) {
+			t.Errorf("%s: got %v, want a cycle %v", tt.name, err, tt.want)
+		}
+	}
+
+	var cycle *CycleError
+	for name, tasks := range map[string][]Task{
+		"unknown dependency": {{Name: "a", DependsOn: []string{"b"}}},
+		"declared twice":     {{Name: "a"}, {Name: "a"}},
+	} {
+		if _, err := NewStartupGraph(tasks...); err == nil || errors.As(err, &cycle) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestStartRunsIndependentTasksAtOnce(t *testing.T) {
+	var e events
+	var both sync.WaitGroup
+	both.Add(2)
+	together := func(name string) Task {
+		task := e.task(name)
+		task.Timeout = 10 * time.Second
+		task.Start = func(ctx context.Context) error {
+			both.Done()
+			both.Wait() // only returns once the other one is starting too
+			e.add("start " + name)
+			return nil
+		}
+		return task
+	}
+	g, err := NewStartupGraph(together("tv"), together("speakers"), e.task("game", "tv", "speakers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if log := e.get(); len(log) != 3 || log[2] != "start game" {
+		t.Errorf("started %v; want tv and speakers, then game", log)
+	}
+}
+
+// checkStopOrder makes sure nothing stopped before the tasks that need it.
+func checkStopOrder(t *testing.T, log []string, tasks []Task) {
+	t.Helper()
+	for _, task := range tasks {
+		i := slices.Index(log, "stop "+task.Name)
+		for _, dep := range task.DependsOn {
+			if j := slices.Index(log, "stop "+dep); i < 0 || j < i {
+				t.Errorf("%s stopped before %s, which needs it: %v", dep, task.Name, log)
+			}
+		}
+	}
+}
+
+func TestStopInReverse(t *testing.T) {
+	var e events
+	tasks := []Task{
+		e.task("config"),
+		e.task("database", "config"),
+		e.task("cache", "config"),
+		e.task("api", "database", "cache"),
+		e.task("metrics"),
+	}
+	g, err := NewStartupGraph(tasks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	log := e.get()
+	if len(log) != 10 {
+		t.Fatalf("got %v", log)
+	}
+	checkStopOrder(t, log, tasks)
+
+	// Stopping twice does nothing the second time.
+	g.Stop(context.Background())
+	if len(e.get()) != 10 {
+		t.Errorf("second Stop stopped something again: %v", e.get())
+	}
+}
+
+func TestTaskTimeoutRollsBack(t *testing.T) {
+	var e events
+	hang := e.task("cache", "config")
+	hang.Timeout = 20 * time.Millisecond
+	hang.Start = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tasks := []Task{e.task("config"), e.task("database", "config"), hang, e.task("api", "database", "cache")}
+	g, err := NewStartupGraph(tasks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Start(context.Background())
+
+	var rollback *RollbackError
+	if !errors.As(err, &rollback) || rollback.Step != "cache" || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v; want cache timing out", err)
+	}
+	log := e.get()
+	if slices.Contains(log, "start api") || slices.Contains(log, "stop cache") {
+		t.Errorf("api started or cache stopped: %v", log)
+	}
+	checkStopOrder(t, log, tasks[:2])
+	if !slices.Contains(log, "stop config") {
+		t.Errorf("config left running: %v", log)
+	}
+}
+
+func TestStartThatIgnoresItsTimeoutIsStoppedLater(t *testing.T) {
+	var e events
+	release := make(chan struct{})
+	stopped := make(chan struct{})
+	stubborn := Task{
+		Name:    "stubborn",
+		Timeout: 10 * time.Millisecond,
+		Start: func(context.Context) error {
+			<-release // ignores ctx on purpose
+			e.add("start stubborn")
+			return nil
+		},
+		Stop: func(context.Context) error {
+			e.add("stop stubborn")
+			close(stopped)
+			return nil
+		},
+	}
+	g, err := NewStartupGraph(stubborn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Start(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v; want a timeout", err)
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the late start was never stopped")
+	}
+	if log := e.get(); !slices.Equal(log, []string{"start stubborn", "stop stubborn"}) {
+		t.Errorf("got %v", log)
+	}
+}
+
+func TestFailureCancelsTheOthers(t *testing.T) {
+	var e events
+	boom := errors.New("boom")
+	broken := e.task("tv")
+	broken.Start = func(context.Context) error { return boom }
+	slow := e.task("console")
+	slow.Start = func(ctx context.Context) error {
+		<-ctx.Done() // only ends because tv failed
+		return ctx.Err()
+	}
+	g, err := NewStartupGraph(broken, slow, e.task("game", "tv", "console"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Start(context.Background())
+	var rollback *RollbackError
+	if !errors.As(err, &rollback) || rollback.Step != "tv" || !errors.Is(err, boom) || errors.Is(err, context.Canceled) {
+		t.Errorf("got %v; want only tv's failure", err)
+	}
+	if log := e.get(); len(log) != 0 {
+		t.Errorf("got %v; want nothing started", log)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Seeing Inside the Facade
+//
+// The facade hides the mess on purpose, but when "PLAY GAME" is slow or fails, someone
+// has to find out which step it was. A Tracer is told when every step starts and ends.
+// Steps started inside another step (through its context) become its children, so the
+// result is a tree: PlayGame -> tv.On, sound.On, ..., rollback -> undo tv.On.
+//
+// With no tracer set, startSpan hands back the same context and an empty span:
+// no clock reads, no allocations.
+
+// Tracer starts spans. The returned context carries the new span, so spans started
+// from it are nested inside.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is one traced step. End it once, with the step's error (or nil).
+type Span interface {
+	End(err error)
+}
+
+type noSpan struct{}
+
+func (noSpan) End(error) {}
+
+// startSpan is how the facade talks to an optional tracer.
+func startSpan(ctx context.Context, t Tracer, name string) (context.Context, Span) {
+	if t == nil {
+		return ctx, noSpan{}
+	}
+	return t.StartSpan(ctx, name)
+}
+
+// traced runs fn inside a span.
+func traced(ctx context.Context, t Tracer, name string, fn func() error) error {
+	_, span := startSpan(ctx, t, name)
+	err := fn()
+	span.End(err)
+	return err
+}
+
+// -- Recording Spans --
+
+// SpanRecord is one span as it was recorded. End is zero while it's still running.
+type SpanRecord struct {
+	ID, Parent int // Parent is 0 for a top-level span
+	Name       string
+	Start, End time.Time
+	Err        error
+}
+
+// recorder keeps every span in memory. Both tracers below are built on it.
+type recorder struct {
+	mu    sync.Mutex
+	spans []*SpanRecord
+	now   func() time.Time // for tests; nil means time.Now
+}
+
+func (r *recorder) clock() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
+}
+
+type spanKey struct{}
+
+// recordedSpan ties a record to the recorder that owns it, so two tracers never
+// mistake each other's spans for parents.
+type recordedSpan struct {
+	r   *recorder
+	rec *SpanRecord
+}
+
+func (r *recorder) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	r.mu.Lock()
+	rec := &SpanRecord{ID: len(r.spans) + 1, Name: name, Start: r.clock()}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok && parent.r == r {
+		rec.Parent = parent.rec.ID
+	}
+	r.spans = append(r.spans, rec)
+	r.mu.Unlock()
+
+	s := &recordedSpan{r: r, rec: rec}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *recordedSpan) End(err error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	if s.rec.End.IsZero() {
+		s.rec.End, s.rec.Err = s.r.clock(), err
+	}
+}
+
+// Spans returns a copy of everything recorded so far, in the order spans started.
+func (r *recorder) Spans() []SpanRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]SpanRecord, len(r.spans))
+	for i, s := range r.spans {
+		out[i] = *s
+	}
+	return out
+}
+
+// -- Tree Printer --
+
+// TreeTracer records spans and prints them as an indented tree with timings.
+type TreeTracer struct {
+	recorder
+}
+
+func NewTreeTracer() *TreeTracer {
+	return &TreeTracer{}
+}
+
+// Print writes the tree, e.g.
+//
+//	PlayGame 1.2ms FAILED: ...
+//	├─ tv.On 0.1ms
+//	└─ rollback 0.3ms
+//	   └─ undo tv.On 0.1ms
+func (t *TreeTracer) Print(w io.Writer) error {
+	spans := t.Spans()
+	children := map[int][]SpanRecord{}
+	for _, s := range spans {
+		children[s.Parent] = append(children[s.Parent], s)
+	}
+
+	var b strings.Builder
+	var print func(s SpanRecord, indent, branch string)
+	print = func(s SpanRecord, indent, branch string) {
+		fmt.Fprintf(&b, "%s%s%s", indent, branch, s.Name)
+		if s.End.IsZero() {
+			b.WriteString(" (still running)")
+		} else {
+			fmt.Fprintf(&b, " %v", s.End.Sub(s.Start).Round(time.Microsecond))
+		}
+		if s.Err != nil {
+			fmt.Fprintf(&b, " FAILED: %v", s.Err)
+		}
+		b.WriteByte('\n')
+
+		switch branch {
+		case "├─ ":
+			indent += "│  "
+		case "└─ ":
+			indent += "   "
+		}
+		kids := children[s.ID]
+		for i, kid := range kids {
+			if i == len(kids)-1 {
+				print(kid, indent, "└─ ")
+			} else {
+				print(kid, indent, "├─ ")
+			}
+		}
+	}
+	for _, root := range children[0] {
+		print(root, "", "")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// -- Trace Viewer Export --
+
+// TraceEventTracer records spans and writes them in the Trace Event Format, which
+// chrome://tracing, Perfetto (ui.perfetto.dev) and speedscope can open.
+type TraceEventTracer struct {
+	recorder
+}
+
+func NewTraceEventTracer() *TraceEventTracer {
+	return &TraceEventTracer{}
+}
+
+// traceEvent is one "complete" event (ph "X"). Times are in microseconds.
+type traceEvent struct {
+	Name string            `json:"name"`
+	Cat  string            `json:"cat"`
+	Ph   string            `json:"ph"`
+	TS   int64             `json:"ts"`
+	Dur  int64             `json:"dur"`
+	PID  int               `json:"pid"`
+	TID  int               `json:"tid"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// WriteJSON writes every finished span. Viewers nest events on the same thread row by time,
+// so steps that ran at the same time are put on separate rows.
+func (t *TraceEventTracer) WriteJSON(w io.Writer) error {
+	spans := slices.DeleteFunc(t.Spans(), func(s SpanRecord) bool { return s.End.IsZero() })
+	if len(spans) == 0 {
+		_, err := io.WriteString(w, `{"traceEvents":[]}`+"\n")
+		return err
+	}
+	origin := spans[0].Start
+	lanes := assignLanes(spans)
+
+	events := make([]traceEvent, 0, len(spans))
+	for i, s := range spans {
+		e := traceEvent{
+			Name: s.Name,
+			Cat:  "facade",
+			Ph:   "X",
+			TS:   s.Start.Sub(origin).Microseconds(),
+			Dur:  s.End.Sub(s.Start).Microseconds(),
+			PID:  1,
+			TID:  lanes[i] + 1,
+		}
+		if s.Err != nil {
+			e.Args = map[string]string{"error": s.Err.Error()}
+		}
+		events = append(events, e)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{"traceEvents": events, "displayTimeUnit": "ms"})
+}
+
+// assignLanes gives every span a row. A span shares a row only with its ancestors and
+// with spans it doesn't overlap in time; it tries its parent's row first.
+func assignLanes(spans []SpanRecord) []int {
+	index := map[int]int{} // span ID -> position in spans
+	for i, s := range spans {
+		index[s.ID] = i
+	}
+	isAncestor := func(a, of SpanRecord) bool {
+		for p := of.Parent; p != 0; {
+			i, ok := index[p]
+			if !ok {
+				return false
+			}
+			if spans[i].ID == a.ID {
+				return true
+			}
+			p = spans[i].Parent
+		}
+		return false
+	}
+	fits := func(lane []int, s SpanRecord) bool {
+		for _, i := range lane {
+			o := spans[i]
+			overlaps := o.Start.Before(s.End) && s.Start.Before(o.End)
+			if overlaps && !isAncestor(o, s) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var rows [][]int
+	lanes := make([]int, len(spans))
+	for i, s := range spans {
+		lane := -1
+		if p, ok := index[s.Parent]; ok && s.Parent != 0 && fits(rows[lanes[p]], s) {
+			lane = lanes[p]
+		}
+		for l := 0; lane < 0 && l < len(rows); l++ {
+			if fits(rows[l], s) {
+				lane = l
+			}
+		}
+		if lane < 0 {
+			lane = len(rows)
+			rows = append(rows, nil)
+		}
+		rows[lane] = append(rows[lane], i)
+		lanes[i] = lane
+	}
+	return lanes
+}
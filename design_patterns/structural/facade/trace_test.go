@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stepClock is a fake recorder.now: every reading is one step later than the last.
+func stepClock(step time.Duration) func() time.Time {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func TestNilTracerAllocatesNothing(t *testing.T) {
+	ctx := context.Background()
+	fn := func() error { return nil }
+	allocs := testing.AllocsPerRun(100, func() {
+		_, span := startSpan(ctx, nil, "tv.On")
+		span.End(nil)
+		traced(ctx, nil, "tv.On", fn)
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per run without a tracer, want 0", allocs)
+	}
+}
+
+func TestTreeTracerPrint(t *testing.T) {
+	tree := NewTreeTracer()
+	tree.now = stepClock(time.Millisecond)
+	boom := errors.New("boom")
+
+	ctx, play := tree.StartSpan(context.Background(), "PlayGame")
+	traced(ctx, tree, "tv.On", func() error { return nil })
+	rollback, span := tree.StartSpan(ctx, "rollback")
+	traced(rollback, tree, "undo tv.On", func() error { return nil })
+	span.End(nil)
+	play.End(boom)
+	play.End(nil) // only the first End counts
+	tree.StartSpan(context.Background(), "QuickStop")
+
+	var out bytes.Buffer
+	if err := tree.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `PlayGame 7ms FAILED: boom
+├─ tv.On 1ms
+└─ rollback 3ms
+   └─ undo tv.On 1ms
+QuickStop (still running)
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestSpansOfAnotherTracerAreNotParents(t *testing.T) {
+	a, b := NewTreeTracer(), NewTreeTracer()
+	ctx, span := a.StartSpan(context.Background(), "outer")
+	defer span.End(nil)
+	b.StartSpan(ctx, "inner")
+	if spans := b.Spans(); len(spans) != 1 || spans[0].Parent != 0 {
+		t.Errorf("got %+v; want a top-level span", spans)
+	}
+}
+
+func TestTraceEventJSON(t *testing.T) {
+	var empty bytes.Buffer
+	if err := NewTraceEventTracer().WriteJSON(&empty); err != nil || empty.String() != `{"traceEvents":[]}`+"\n" {
+		t.Errorf("empty trace: %q, %v", empty.String(), err)
+	}
+
+	tr := NewTraceEventTracer()
+	tr.now = stepClock(time.Millisecond)
+	ctx, play := tr.StartSpan(context.Background(), "PlayGame")
+	_, tv := tr.StartSpan(ctx, "tv.On")
+	_, sound := tr.StartSpan(ctx, "sound.On") // at the same time as tv.On
+	tv.End(nil)
+	sound.End(nil)
+	play.End(errors.New("boom"))
+	tr.StartSpan(context.Background(), "unfinished")
+
+	var out bytes.Buffer
+	if err := tr.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		TraceEvents     []traceEvent
+		DisplayTimeUnit string
+	}
+	dec := json.NewDecoder(&out)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []traceEvent{
+		{Name: "PlayGame", Cat: "facade", Ph: "X", TS: 0, Dur: 5000, PID: 1, TID: 1, Args: map[string]string{"error": "boom"}},
+		{Name: "tv.On", Cat: "facade", Ph: "X", TS: 1000, Dur: 2000, PID: 1, TID: 1},
+		{Name: "sound.On", Cat: "facade", Ph: "X", TS: 2000, Dur: 2000, PID: 1, TID: 2},
+	}
+	if got.DisplayTimeUnit != "ms" || len(got.TraceEvents) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i, e := range got.TraceEvents {
+		if e.Name != want[i].Name || e.TS != want[i].TS || e.Dur != want[i].Dur || e.TID != want[i].TID ||
+			e.Ph != want[i].Ph || e.Cat != want[i].Cat || e.PID != want[i].PID || e.Args["error"] != want[i].Args["error"] {
+			t.Errorf("event %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestAssignLanes(t *testing.T) {
+	at := func(ms int) time.Time {
+		return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).Add(time.Duration(ms) * time.Millisecond)
+	}
+	span := func(id, parent, start, end int) SpanRecord {
+		return SpanRecord{ID: id, Parent: parent, Start: at(start), End: at(end)}
+	}
+	spans := []SpanRecord{
+		span(1, 0, 0, 10),  // PlayGame
+		span(2, 1, 1, 4),   // its child: same row
+		span(3, 1, 2, 6),   // overlaps 2: a new row
+		span(4, 1, 5, 8),   // 2 is over by now: back to the parent's row
+		span(5, 0, 3, 5),   // another top-level span during all this: neither row fits
+		span(6, 3, 3, 4),   // a child of 3 fits 3's row
+		span(7, 0, 11, 12), // after everything: the first row again
+	}
+	if got, want := assignLanes(spans), []int{0, 0, 1, 0, 2, 1, 0}; !slices.Equal(got, want) {
+		t.Errorf("lanes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,250 @@
+// Package codegen is what decoratorgen and proxygen have in common: type-checking the
+// package that declares an interface, and writing a Go file into that package that
+// refers to types from anywhere else.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goFiles parses the .go files in dir that belong to the package for this platform and
+// build tags, leaving out tests and any file for which skip returns true.
+func goFiles(fset *token.FileSet, dir string, skip func(name string) bool) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || !strings.HasSuffix(n, ".go") || strings.HasSuffix(n, "_test.go") || skip(n) {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, n); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, n), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return files, nil
+}
+
+// LoadPackage parses the package in dir, as it would be built here, and type-checks it.
+// The file called skip (usually the generator's previous output) is left out, so a stale
+// generated file can never stop the generator from running.
+// Code that uses the generated types can't compile until they exist, so "undefined"
+// errors for the names in generated are expected; any other type error is returned.
+func LoadPackage(dir, skip string, generated ...string) (*types.Package, error) {
+	fset := token.NewFileSet()
+	files, err := goFiles(fset, dir, func(n string) bool { return n == skip })
+	if err != nil {
+		return nil, err
+	}
+
+	var first error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			if te, ok := err.(types.Error); ok {
+				for _, name := range generated {
+					if te.Msg == "undefined: "+name {
+						return
+					}
+				}
+			}
+			if first == nil {
+				first = err
+			}
+		},
+	}
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, nil)
+	if first != nil {
+		return nil, first
+	}
+	return pkg, nil
+}
+
+// Compile builds and vets the package in dir as if the extra files (name -> source) were
+// in it too, and returns what the go command said if it failed. The generators' tests use
+// it to prove that their output compiles next to the code that uses it.
+// The package is copied into a module of its own, so it may only import the standard library.
+func Compile(dir string, extra map[string][]byte) error {
+	tmp, err := os.MkdirTemp("", "codegen")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	fset := token.NewFileSet()
+	files, err := goFiles(fset, dir, func(n string) bool { return extra[n] != nil })
+	if err != nil {
+		return err
+	}
+	copies := map[string][]byte{"go.mod": []byte("module codegencheck\n\ngo 1.23\n")}
+	for _, f := range files {
+		name := filepath.Base(fset.File(f.Pos()).Name())
+		if copies[name], err = os.ReadFile(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	for n, src := range extra {
+		copies[n] = src
+	}
+	for n, src := range copies {
+		if err := os.WriteFile(filepath.Join(tmp, n), src, 0o644); err != nil {
+			return err
+		}
+	}
+
+	for _, args := range [][]string{{"build", "."}, {"vet", "."}} {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = tmp
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("go %s: %w\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	return nil
+}
+
+// LookupInterface finds the interface called name in pkg.
+func LookupInterface(pkg *types.Package, name string) (*types.Interface, error) {
+	obj := pkg.Scope().Lookup(name)
+	if obj == nil {
+		return nil, fmt.Errorf("type %s not found in package %s", name, pkg.Name())
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, fmt.Errorf("%s is a %s, not an interface", name, obj.Type().Underlying())
+	}
+	return iface, nil
+}
+
+// File is a generated Go file in the package pkg.
+type File struct {
+	pkg     *types.Package
+	imports map[string]string // path -> name
+	names   map[string]string // name -> path, so two packages never share a name
+	body    bytes.Buffer
+}
+
+func NewFile(pkg *types.Package) *File {
+	return &File{pkg: pkg, imports: map[string]string{}, names: map[string]string{}}
+}
+
+func (f *File) Printf(format string, args ...any) {
+	fmt.Fprintf(&f.body, format, args...)
+}
+
+// Import remembers an import and returns the name to refer to it by. When another path
+// already took that name (html/template and text/template), it gets a numbered alias.
+// Import the packages the generator writes out by hand first, so they keep their names.
+func (f *File) Import(path, name string) string {
+	if n, ok := f.imports[path]; ok {
+		return n
+	}
+	alias := name
+	for i := 2; f.names[alias] != ""; i++ {
+		alias = fmt.Sprintf("%s%d", name, i)
+	}
+	f.imports[path] = alias
+	f.names[alias] = path
+	return alias
+}
+
+// qualify writes types from other packages as pkg.Type and remembers the import.
+func (f *File) qualify(p *types.Package) string {
+	if p == f.pkg {
+		return ""
+	}
+	return f.Import(p.Path(), p.Name())
+}
+
+// TypeString writes t as it must be spelled inside the generated file.
+func (f *File) TypeString(t types.Type) string {
+	return types.TypeString(t, f.qualify)
+}
+
+// Params describes a method's parameters as a0, a1, ...:
+// decl for the signature ("a0 string, a1 ...any"), names for []any{...},
+// and forward for calling the same method on something else ("a0, a1...").
+func (f *File) Params(sig *types.Signature) (decl, names, forward []string) {
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		p := fmt.Sprintf("a%d", i)
+		t := params.At(i).Type()
+		if sig.Variadic() && i == params.Len()-1 {
+			decl = append(decl, p+" ..."+f.TypeString(t.(*types.Slice).Elem()))
+			forward = append(forward, p+"...")
+		} else {
+			decl = append(decl, p+" "+f.TypeString(t))
+			forward = append(forward, p)
+		}
+		names = append(names, p)
+	}
+	return decl, names, forward
+}
+
+// Results describes a method's results as r0, r1, ...: their types, their names, and the
+// result list for the signature ("", "error" or "(int, error)").
+func (f *File) Results(sig *types.Signature) (typeNames, names []string, list string) {
+	results := sig.Results()
+	for i := 0; i < results.Len(); i++ {
+		typeNames = append(typeNames, f.TypeString(results.At(i).Type()))
+		names = append(names, fmt.Sprintf("r%d", i))
+	}
+	list = strings.Join(typeNames, ", ")
+	if len(typeNames) > 1 {
+		list = "(" + list + ")"
+	}
+	return typeNames, names, list
+}
+
+// Source returns the whole file, formatted, with the header saying which generator wrote it.
+func (f *File) Source(generator string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by %s; DO NOT EDIT.\n\n", generator)
+	fmt.Fprintf(&out, "package %s\n\n", f.pkg.Name())
+
+	paths := make([]string, 0, len(f.imports))
+	for p := range f.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	fmt.Fprintf(&out, "import (\n")
+	for _, p := range paths {
+		if name := f.imports[p]; name != filepath.Base(p) {
+			fmt.Fprintf(&out, "%s %q\n", name, p)
+		} else {
+			fmt.Fprintf(&out, "%q\n", p)
+		}
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	out.Write(f.body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not parse: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
@@ -0,0 +1,86 @@
+package codegen
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestImportAliasesCollidingNames(t *testing.T) {
+	f := NewFile(types.NewPackage("example.com/here", "here"))
+	for _, tt := range []struct{ path, name, want string }{
+		{"text/template", "template", "template"},
+		{"html/template", "template", "template2"},
+		{"example.com/other/template", "template", "template3"},
+		{"text/template", "template", "template"}, // same path, same name as before
+		{"gopkg.in/yaml.v3", "yaml", "yaml"},
+	} {
+		if got := f.Import(tt.path, tt.name); got != tt.want {
+			t.Errorf("Import(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	f.Printf("var _ = 1\n")
+	src, err := f.Source("codegen_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Code generated by codegen_test; DO NOT EDIT.",
+		"package here",
+		`template3 "example.com/other/template"`,
+		`template2 "html/template"`,
+		`"text/template"`,
+		`yaml "gopkg.in/yaml.v3"`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("missing %q in:\n%s", want, src)
+		}
+	}
+}
+
+func TestLookupInterface(t *testing.T) {
+	pkg := types.NewPackage("example.com/here", "here")
+	iface := types.NewInterfaceType(nil, nil)
+	pkg.Scope().Insert(types.NewTypeName(0, pkg, "Door", iface))
+	pkg.Scope().Insert(types.NewTypeName(0, pkg, "Wall", types.NewStruct(nil, nil)))
+
+	if _, err := LookupInterface(pkg, "Door"); err != nil {
+		t.Error(err)
+	}
+	if _, err := LookupInterface(pkg, "Wall"); err == nil || !strings.Contains(err.Error(), "not an interface") {
+		t.Errorf("Wall: %v", err)
+	}
+	if _, err := LookupInterface(pkg, "Window"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Window: %v", err)
+	}
+}
+
+func TestLoadPackage(t *testing.T) {
+	if _, err := LoadPackage("testdata/broken", "", "DoorProxy"); err == nil || !strings.Contains(err.Error(), "three") {
+		t.Errorf("broken package: got %v, want its type error", err)
+	}
+	if _, err := LoadPackage("testdata/broken", ""); err == nil {
+		t.Error("broken package: an undefined name nobody is generating was let through")
+	}
+
+	pkg, err := LoadPackage("testdata/tagged", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iface, err := LookupInterface(pkg, "Door")
+	if err != nil || iface.NumMethods() != 1 || iface.Method(0).Name() != "Open" {
+		t.Errorf("got %v, %v; want the Door from door.go only", iface, err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	proxy := []byte("package tagged\n\ntype DoorProxy struct{ Door }\n")
+	if err := Compile("testdata/tagged", map[string][]byte{"door_proxy.go": proxy}); err != nil {
+		t.Error(err)
+	}
+	vetFails := []byte("package tagged\n\nimport \"fmt\"\n\nfunc knock() { fmt.Printf(\"%d\", \"knock\") }\n")
+	if err := Compile("testdata/tagged", map[string][]byte{"knock.go": vetFails}); err == nil || !strings.Contains(err.Error(), "go vet") {
+		t.Errorf("got %v, want go vet to complain", err)
+	}
+}
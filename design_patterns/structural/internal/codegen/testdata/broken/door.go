@@ -0,0 +1,11 @@
+package broken
+
+type Door interface {
+	Open() error
+}
+
+// Not a mistake the generator is going to fix.
+var count int = "three"
+
+// Is going to exist once the proxy is generated.
+var _ Door = (*DoorProxy)(nil)
@@ -0,0 +1,5 @@
+package tagged
+
+type Door interface {
+	Open() error
+}
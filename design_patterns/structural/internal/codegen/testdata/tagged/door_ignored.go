@@ -0,0 +1,8 @@
+//go:build ignore
+
+package tagged
+
+// Never part of the build, so it must not clash with door.go.
+type Door interface {
+	Close()
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Caching Proxy
+//
+// The "Cache" half of the Real World Scenario in main.go.
+// The slow database and the CachingProxy both implement Fetcher, so callers can't tell them apart.
+// The proxy remembers answers for a while (TTL), forgets the least recently used ones when full (LRU),
+// asks the database only once when many callers want the same missing key at the same time,
+// and also remembers failures for a short while so a broken key doesn't hammer the database.
+
+// Fetcher is the subject: anything that can look up a value by key.
+type Fetcher[K comparable, V any] interface {
+	Fetch(ctx context.Context, key K) (V, error)
+}
+
+// FetcherFunc lets a plain function be used as a Fetcher.
+type FetcherFunc[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+func (f FetcherFunc[K, V]) Fetch(ctx context.Context, key K) (V, error) {
+	return f(ctx, key)
+}
+
+// CacheConfig tunes the CachingProxy. Zero values mean "no limit" / "don't cache".
+type CacheConfig struct {
+	TTL         time.Duration    // how long a value stays fresh; 0 keeps it until evicted
+	NegativeTTL time.Duration    // how long an error is remembered; 0 never caches errors
+	MaxEntries  int              // LRU size bound; 0 is unbounded
+	Now         func() time.Time // clock, for tests; defaults to time.Now
+}
+
+// CacheStats counts what the proxy did.
+type CacheStats struct {
+	Hits         int // served a fresh value from memory
+	NegativeHits int // served a remembered error from memory
+	Misses       int // had to ask the real fetcher
+	Shared       int // waited on another caller's in-flight fetch instead of fetching again
+	Evictions    int // entries dropped to respect MaxEntries
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	err     error
+	expires time.Time // zero means never
+}
+
+type inflightFetch[V any] struct {
+	done    chan struct{}
+	value   V
+	err     error
+	waiters int                // callers still waiting; guarded by CachingProxy.mu
+	cancel  context.CancelFunc // called when the last waiter gives up
+}
+
+// CachingProxy sits in front of a slow Fetcher.
+type CachingProxy[K comparable, V any] struct {
+	real Fetcher[K, V]
+	cfg  CacheConfig
+
+	mu       sync.Mutex
+	entries  map[K]*list.Element // values are *cacheEntry[K, V]
+	lru      *list.List          // front is most recently used
+	inflight map[K]*inflightFetch[V]
+	stats    CacheStats
+}
+
+func NewCachingProxy[K comparable, V any](real Fetcher[K, V], cfg CacheConfig) *CachingProxy[K, V] {
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &CachingProxy[K, V]{
+		real:     real,
+		cfg:      cfg,
+		entries:  map[K]*list.Element{},
+		lru:      list.New(),
+		inflight: map[K]*inflightFetch[V]{},
+	}
+}
+
+func (c *CachingProxy[K, V]) Fetch(ctx context.Context, key K) (V, error) {
+	c.mu.Lock()
+	if e, ok := c.lookup(key); ok {
+		if e.err != nil {
+			c.stats.NegativeHits++
+		} else {
+			c.stats.Hits++
+		}
+		c.mu.Unlock()
+		return e.value, e.err
+	}
+
+	if err := ctx.Err(); err != nil {
+		c.mu.Unlock()
+		var zero V
+		return zero, err
+	}
+	call, shared := c.inflight[key]
+	if shared {
+		c.stats.Shared++
+	} else {
+		c.stats.Misses++
+		// Detached from ctx: one impatient caller must not fail everybody waiting on this key.
+		// It is cancelled once nobody is waiting any more.
+		fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &inflightFetch[V]{done: make(chan struct{}), cancel: cancel}
+		c.inflight[key] = call
+		go c.fetch(fetchCtx, key, call)
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		if call.waiters--; call.waiters == 0 && c.inflight[key] == call {
+			// Forget it now: a fetch that ignores cancellation must not hold up the next caller.
+			delete(c.inflight, key)
+			call.cancel()
+		}
+		c.mu.Unlock()
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (c *CachingProxy[K, V]) fetch(ctx context.Context, key K, call *inflightFetch[V]) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = fmt.Errorf("fetch %v: panic: %v", key, r)
+		}
+		c.mu.Lock()
+		if c.inflight[key] == call { // not abandoned by every waiter
+			delete(c.inflight, key)
+			c.store(key, call.value, call.err)
+		}
+		c.mu.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+	call.value, call.err = c.real.Fetch(ctx, key)
+}
+
+// lookup returns a fresh entry and marks it as recently used. Must hold c.mu.
+func (c *CachingProxy[K, V]) lookup(key K) (*cacheEntry[K, V], bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry[K, V])
+	if !e.expires.IsZero() && !c.cfg.Now().Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+// store remembers a result and evicts the least recently used entries if needed. Must hold c.mu.
+func (c *CachingProxy[K, V]) store(key K, value V, err error) {
+	ttl := c.cfg.TTL
+	if err != nil {
+		// Cancellations say nothing about the key itself, so they are never remembered.
+		if c.cfg.NegativeTTL <= 0 || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		ttl = c.cfg.NegativeTTL
+	}
+	e := &cacheEntry[K, V]{key: key, value: value, err: err}
+	if ttl > 0 {
+		e.expires = c.cfg.Now().Add(ttl)
+	}
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[key] = c.lru.PushFront(e)
+	}
+	for c.cfg.MaxEntries > 0 && c.lru.Len() > c.cfg.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry[K, V]).key)
+		c.stats.Evictions++
+	}
+}
+
+// Stats returns a snapshot of the counters.
+func (c *CachingProxy[K, V]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Len is the number of cached entries, fresh or not yet noticed as expired.
+func (c *CachingProxy[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// -- The slow Real Subject --
+
+// ErrMemberNotFound is what the database says about a name it doesn't know.
+var ErrMemberNotFound = errors.New("member not found")
+
+// SlowMemberDatabase looks up clubhouse members, very slowly.
+type SlowMemberDatabase struct {
+	mu      sync.Mutex
+	members map[string]string
+	queries int
+}
+
+func (db *SlowMemberDatabase) Fetch(ctx context.Context, name string) (string, error) {
+	db.mu.Lock()
+	db.queries++
+	db.mu.Unlock()
+
+	select {
+	case <-time.After(100 * time.Millisecond): // pretend the database is far away
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if role, ok := db.members[name]; ok {
+		return role, nil
+	}
+	return "", fmt.Errorf("%q: %w", name, ErrMemberNotFound)
+}
+
+// Queries is how many times the database really got asked.
+func (db *SlowMemberDatabase) Queries() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.queries
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when a test tells it to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// countingFetcher answers "value of <key>", or fails for keys in fail.
+type countingFetcher struct {
+	calls atomic.Int32
+	fail  map[string]bool
+}
+
+func (f *countingFetcher) Fetch(ctx context.Context, key string) (string, error) {
+	f.calls.Add(1)
+	if f.fail[key] {
+		return "", fmt.Errorf("%q: %w", key, ErrMemberNotFound)
+	}
+	return "value of " + key, nil
+}
+
+func fetch(t *testing.T, c *CachingProxy[string, string], key string) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.Fetch(ctx, key)
+}
+
+func TestCacheTTL(t *testing.T) {
+	clock := newFakeClock()
+	real := &countingFetcher{}
+	c := NewCachingProxy[string, string](real, CacheConfig{TTL: time.Minute, Now: clock.Now})
+
+	for range 3 {
+		if v, err := fetch(t, c, "ada"); v != "value of ada" || err != nil {
+			t.Fatalf("got %q, %v", v, err)
+		}
+	}
+	clock.Advance(59 * time.Second)
+	fetch(t, c, "ada")
+	if n := real.calls.Load(); n != 1 {
+		t.Fatalf("%d fetches before the TTL ran out, want 1", n)
+	}
+
+	clock.Advance(time.Second)
+	fetch(t, c, "ada")
+	if n := real.calls.Load(); n != 2 {
+		t.Errorf("%d fetches after the TTL ran out, want 2", n)
+	}
+	if s := c.Stats(); s.Hits != 3 || s.Misses != 2 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestCacheLRU(t *testing.T) {
+	real := &countingFetcher{}
+	c := NewCachingProxy[string, string](real, CacheConfig{MaxEntries: 2})
+
+	fetch(t, c, "a")
+	fetch(t, c, "b")
+	fetch(t, c, "a") // a is now the most recently used
+	fetch(t, c, "c") // so b goes
+	if c.Len() != 2 || c.Stats().Evictions != 1 {
+		t.Fatalf("len %d, stats %+v", c.Len(), c.Stats())
+	}
+
+	real.calls.Store(0)
+	fetch(t, c, "a")
+	fetch(t, c, "c")
+	if n := real.calls.Load(); n != 0 {
+		t.Errorf("a and c should still be cached, but fetched %d times", n)
+	}
+	fetch(t, c, "b")
+	if n := real.calls.Load(); n != 1 {
+		t.Errorf("b should have been evicted, fetched %d times", n)
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	clock := newFakeClock()
+	real := &countingFetcher{fail: map[string]bool{"nobody": true}}
+	c := NewCachingProxy[string, string](real, CacheConfig{TTL: time.Hour, NegativeTTL: time.Second, Now: clock.Now})
+
+	for range 3 {
+		if _, err := fetch(t, c, "nobody"); !errors.Is(err, ErrMemberNotFound) {
+			t.Fatalf("got %v, want ErrMemberNotFound", err)
+		}
+	}
+	if n := real.calls.Load(); n != 1 {
+		t.Fatalf("the error was fetched %d times, want 1", n)
+	}
+	clock.Advance(time.Second)
+	fetch(t, c, "nobody")
+	if n := real.calls.Load(); n != 2 {
+		t.Errorf("the error should have expired, fetched %d times", n)
+	}
+	if s := c.Stats(); s.NegativeHits != 2 {
+		t.Errorf("stats %+v", s)
+	}
+
+	// Without a NegativeTTL errors are never remembered.
+	c = NewCachingProxy[string, string](real, CacheConfig{TTL: time.Hour, Now: clock.Now})
+	fetch(t, c, "nobody")
+	fetch(t, c, "nobody")
+	if n := real.calls.Load(); n != 4 {
+		t.Errorf("fetched %d times in total, want 4", n)
+	}
+}
+
+func TestCacheSharesConcurrentMisses(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	real := FetcherFunc[string, string](func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		<-release
+		return "value of " + key, nil
+	})
+	c := NewCachingProxy[string, string](real, CacheConfig{})
+
+	const callers = 50
+	var wg sync.WaitGroup
+	results := make(chan string, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := fetch(t, c, "ada")
+			if err != nil {
+				t.Error(err)
+			}
+			results <- v
+		}()
+	}
+	// Let every caller queue up behind the first before the fetch finishes.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if s := c.Stats(); s.Misses+s.Shared == callers {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("callers never queued up: %+v", c.Stats())
+		}
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		if v != "value of ada" {
+			t.Errorf("got %q", v)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+	if s := c.Stats(); s.Misses != 1 || s.Shared != callers-1 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+// waitFor polls until cond holds, for goroutines a test can't synchronize with directly.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+	}
+}
+
+func TestCacheImpatientCallerDoesNotFailOthers(t *testing.T) {
+	release := make(chan struct{})
+	var fetchErr atomic.Value
+	real := FetcherFunc[string, string](func(ctx context.Context, key string) (string, error) {
+		select {
+		case <-release:
+			return "value of " + key, nil
+		case <-ctx.Done():
+			fetchErr.Store(ctx.Err())
+			return "", ctx.Err()
+		}
+	})
+	c := NewCachingProxy[string, string](real, CacheConfig{})
+
+	patient := make(chan string)
+	go func() {
+		v, _ := fetch(t, c, "ada")
+		patient <- v
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	impatient := make(chan error)
+	go func() {
+		_, err := c.Fetch(ctx, "ada")
+		impatient <- err
+	}()
+	waitFor(t, "both callers wait", func() bool { s := c.Stats(); return s.Misses+s.Shared == 2 })
+
+	cancel()
+	if err := <-impatient; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	close(release)
+	if v := <-patient; v != "value of ada" || fetchErr.Load() != nil {
+		t.Errorf("patient caller got %q; the fetch saw %v", v, fetchErr.Load())
+	}
+}
+
+func TestCacheAbandonedFetchIsCancelledAndForgotten(t *testing.T) {
+	cancelled, stuck := make(chan struct{}), make(chan struct{})
+	t.Cleanup(func() { close(stuck) })
+	var calls atomic.Int32
+	real := FetcherFunc[string, string](func(ctx context.Context, key string) (string, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			close(cancelled)
+			<-stuck // and then doesn't return, like a broken driver
+			return "", ctx.Err()
+		}
+		return "value of " + key, nil
+	})
+	c := NewCachingProxy[string, string](real, CacheConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Fetch(ctx, "ada"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the fetch kept going after its only caller left")
+	}
+	if v, err := fetch(t, c, "ada"); v != "value of ada" || err != nil {
+		t.Errorf("next caller got %q, %v; want a fresh fetch", v, err)
+	}
+}
+
+func TestCacheFetchPanics(t *testing.T) {
+	var calls atomic.Int32
+	real := FetcherFunc[string, string](func(ctx context.Context, key string) (string, error) {
+		if calls.Add(1) == 1 {
+			panic("driver bug")
+		}
+		return "value of " + key, nil
+	})
+	c := NewCachingProxy[string, string](real, CacheConfig{})
+
+	if _, err := fetch(t, c, "ada"); err == nil || !strings.Contains(err.Error(), "driver bug") {
+		t.Fatalf("got %v, want the panic as an error", err)
+	}
+	if v, err := fetch(t, c, "ada"); v != "value of ada" || err != nil {
+		t.Errorf("after a panic: %q, %v", v, err)
+	}
+}
@@ -0,0 +1,77 @@
+// Code generated by proxygen; DO NOT EDIT.
+
+package main
+
+import (
+	"time"
+)
+
+// DoorCall describes one call going through a DoorProxy.
+type DoorCall struct {
+	Method  string
+	Args    []any
+	Results []any // what the caller gets back, set before After runs
+	Err     error // the call's error result, or Authorize's refusal
+	Panic   any   // what Target panicked with, if it did; the panic goes on after After
+	Start   time.Time
+	Elapsed time.Duration
+}
+
+// DoorProxy forwards every Door method to Target, with optional hooks around each call:
+// Before runs just before Target, After runs last (even when refused or panicking).
+// Authorize can refuse calls to Lock, Open.
+type DoorProxy struct {
+	Target    Door
+	Authorize func(call *DoorCall) error
+	Before    func(call *DoorCall)
+	After     func(call *DoorCall)
+}
+
+func (p *DoorProxy) finish(call *DoorCall, panicked any) {
+	call.Elapsed = time.Since(call.Start)
+	call.Panic = panicked
+	if p.After != nil {
+		p.After(call)
+	}
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+func (p *DoorProxy) Lock(a0 string, a1 string) (r0 error) {
+	call := &DoorCall{Method: "Lock", Args: []any{a0, a1}, Start: time.Now()}
+	defer func() {
+		call.Results = []any{r0}
+		call.Err = r0
+		p.finish(call, recover())
+	}()
+	if p.Authorize != nil {
+		if err := p.Authorize(call); err != nil {
+			r0 = err
+			return
+		}
+	}
+	if p.Before != nil {
+		p.Before(call)
+	}
+	return p.Target.Lock(a0, a1)
+}
+
+func (p *DoorProxy) Open(a0 string, a1 string) (r0 error) {
+	call := &DoorCall{Method: "Open", Args: []any{a0, a1}, Start: time.Now()}
+	defer func() {
+		call.Results = []any{r0}
+		call.Err = r0
+		p.finish(call, recover())
+	}()
+	if p.Authorize != nil {
+		if err := p.Authorize(call); err != nil {
+			r0 = err
+			return
+		}
+	}
+	if p.Before != nil {
+		p.Before(call)
+	}
+	return p.Target.Open(a0, a1)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDoorProxyHooks(t *testing.T) {
+	var calls []*DoorCall
+	opened := 0
+	p := &DoorProxy{
+		Target: doorFunc(func() error { opened++; return nil }),
+		Authorize: func(call *DoorCall) error {
+			if call.Args[0] != "alice" {
+				return ErrAccessDenied
+			}
+			return nil
+		},
+		After: func(call *DoorCall) { calls = append(calls, call) },
+	}
+
+	if err := p.Open("alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Open("mallory", ""); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("got %v, want ErrAccessDenied", err)
+	}
+	if opened != 1 {
+		t.Errorf("the door was opened %d times, want 1", opened)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("After ran %d times, want 2", len(calls))
+	}
+	if refused := calls[1]; refused.Err != ErrAccessDenied || len(refused.Results) != 1 || refused.Results[0] != ErrAccessDenied {
+		t.Errorf("refused call: Err %v, Results %v", refused.Err, refused.Results)
+	}
+}
+
+func TestDoorProxyAfterRunsWhenTargetPanics(t *testing.T) {
+	var after *DoorCall
+	p := &DoorProxy{
+		Target: doorFunc(func() error { panic("stuck hinge") }),
+		After:  func(call *DoorCall) { after = call },
+	}
+	func() {
+		defer func() {
+			if v := recover(); v != "stuck hinge" {
+				t.Errorf("recovered %v, want the door's own panic", v)
+			}
+		}()
+		p.Lock("bob", "")
+	}()
+	if after == nil {
+		t.Fatal("After never ran")
+	}
+	if after.Panic != "stuck hinge" || after.Err != nil {
+		t.Errorf("After saw Panic %v, Err %v", after.Panic, after.Err)
+	}
+}
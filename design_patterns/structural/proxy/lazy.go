@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Virtual Proxy
+//
+// Building the real door is expensive, so the guard only builds it when the first kid
+// actually needs it. With many kids knocking at once, a plain `if door == nil` would build
+// several doors (a data race). Lazy makes sure:
+// - the door is built exactly once, however many kids knock at the same time,
+// - if building fails, every kid waiting on that attempt hears about the failure,
+// - the next knock after a failure tries again.
+
+// Lazy holds a value that is built on first use.
+type Lazy[T any] struct {
+	build func() (T, error)
+
+	mu      sync.Mutex
+	built   bool
+	value   T
+	attempt *lazyAttempt[T] // the build in progress, if any
+}
+
+type lazyAttempt[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func NewLazy[T any](build func() (T, error)) *Lazy[T] {
+	return &Lazy[T]{build: build}
+}
+
+// Get returns the value, building it first if nobody has managed to yet.
+func (l *Lazy[T]) Get() (T, error) {
+	l.mu.Lock()
+	if l.built {
+		l.mu.Unlock()
+		return l.value, nil
+	}
+	if a := l.attempt; a != nil {
+		l.mu.Unlock()
+		<-a.done
+		return a.value, a.err
+	}
+	a := &lazyAttempt[T]{done: make(chan struct{})}
+	l.attempt = a
+	l.mu.Unlock()
+
+	a.value, a.err = l.safeBuild()
+
+	l.mu.Lock()
+	if a.err == nil {
+		l.built, l.value = true, a.value
+	}
+	l.attempt = nil // on failure, the next Get starts a fresh attempt
+	l.mu.Unlock()
+	close(a.done)
+	return a.value, a.err
+}
+
+// safeBuild turns a panicking constructor into an error, so waiters are never stuck.
+func (l *Lazy[T]) safeBuild() (v T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("lazy construction panicked: %v", r)
+		}
+	}()
+	return l.build()
+}
+
+// VirtualDoor is a Door that isn't built until someone uses it.
+type VirtualDoor struct {
+	door *Lazy[Door]
+}
+
+func NewVirtualDoor(build func() (Door, error)) *VirtualDoor {
+	return &VirtualDoor{door: NewLazy(build)}
+}
+
+func (v *VirtualDoor) Open(user, password string) error {
+	door, err := v.door.Get()
+	if err != nil {
+		return err
+	}
+	return door.Open(user, password)
+}
+
+func (v *VirtualDoor) Lock(user, password string) error {
+	door, err := v.door.Get()
+	if err != nil {
+		return err
+	}
+	return door.Lock(user, password)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// knock runs n goroutines that all call get at (nearly) the same moment.
+// ready is closed once every goroutine is about to call it.
+func knock(n int, get func()) (ready <-chan struct{}, wait func()) {
+	var arrived, done sync.WaitGroup
+	start := make(chan struct{})
+	allArrived := make(chan struct{})
+	for range n {
+		arrived.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			<-start
+			arrived.Done()
+			get()
+		}()
+	}
+	close(start)
+	go func() { arrived.Wait(); close(allArrived) }()
+	return allArrived, done.Wait
+}
+
+func TestLazyBuildsOnce(t *testing.T) {
+	var builds atomic.Int32
+	l := NewLazy(func() (*RealDoor, error) {
+		builds.Add(1)
+		time.Sleep(time.Millisecond)
+		return &RealDoor{}, nil
+	})
+
+	var mu sync.Mutex
+	doors := map[*RealDoor]bool{}
+	_, wait := knock(100, func() {
+		door, err := l.Get()
+		if err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		doors[door] = true
+		mu.Unlock()
+	})
+	wait()
+
+	if n := builds.Load(); n != 1 {
+		t.Errorf("built %d times, want 1", n)
+	}
+	if len(doors) != 1 {
+		t.Errorf("handed out %d different doors, want 1", len(doors))
+	}
+}
+
+func TestLazyFailureReachesEveryWaiter(t *testing.T) {
+	release := make(chan struct{})
+	var builds atomic.Int32
+	l := NewLazy(func() (int, error) {
+		n := builds.Add(1)
+		<-release
+		return 0, fmt.Errorf("attempt %d failed", n)
+	})
+
+	var mu sync.Mutex
+	errs := map[string]int{}
+	ready, wait := knock(50, func() {
+		_, err := l.Get()
+		mu.Lock()
+		errs[fmt.Sprint(err)]++
+		mu.Unlock()
+	})
+	<-ready
+	time.Sleep(50 * time.Millisecond) // let everybody get stuck behind the first attempt
+	close(release)
+	wait()
+
+	if errs["attempt 1 failed"] != 50 {
+		t.Errorf("got %v, want all 50 to hear about attempt 1", errs)
+	}
+}
+
+func TestLazyRetriesAfterFailure(t *testing.T) {
+	var builds atomic.Int32
+	l := NewLazy(func() (int, error) {
+		if builds.Add(1) < 3 {
+			return 0, errors.New("wood delivery is late")
+		}
+		return 42, nil
+	})
+	for i := range 2 {
+		if _, err := l.Get(); err == nil {
+			t.Fatalf("attempt %d succeeded", i+1)
+		}
+	}
+	for range 3 {
+		if v, err := l.Get(); v != 42 || err != nil {
+			t.Fatalf("got %d, %v", v, err)
+		}
+	}
+	if n := builds.Load(); n != 3 {
+		t.Errorf("built %d times, want 3", n)
+	}
+}
+
+func TestLazyPanicBecomesError(t *testing.T) {
+	calls := 0
+	l := NewLazy(func() (int, error) {
+		if calls++; calls == 1 {
+			panic("the hammer broke")
+		}
+		return 1, nil
+	})
+	if _, err := l.Get(); err == nil {
+		t.Fatal("a panicking build succeeded")
+	}
+	if v, err := l.Get(); v != 1 || err != nil {
+		t.Errorf("retry after a panic: %d, %v", v, err)
+	}
+}
+
+// countingDoor counts opens without printing anything.
+type countingDoor struct{ opens atomic.Int32 }
+
+func (d *countingDoor) Open(user, password string) error { d.opens.Add(1); return nil }
+func (d *countingDoor) Lock(user, password string) error { return nil }
+
+func TestVirtualDoor(t *testing.T) {
+	real := &countingDoor{}
+	var builds atomic.Int32
+	var door Door = NewVirtualDoor(func() (Door, error) {
+		builds.Add(1)
+		return real, nil
+	})
+	if builds.Load() != 0 {
+		t.Fatal("built before anyone knocked")
+	}
+	_, wait := knock(50, func() {
+		if err := door.Open("kid", ""); err != nil {
+			t.Error(err)
+		}
+	})
+	wait()
+	if builds.Load() != 1 || real.opens.Load() != 50 {
+		t.Errorf("%d builds, %d opens", builds.Load(), real.opens.Load())
+	}
+}
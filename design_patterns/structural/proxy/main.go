@@ -1,6 +1,20 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//go:generate go run ./proxygen -type Door
 
 // Proxy Pattern
 //
@@ -17,44 +31,297 @@ import "fmt"
 // - Cache: Before asking the real database (which is slow), check if we already have the answer in memory (fast).
 // - Access Control: Before letting a user delete a file, check if they are an Admin.
 
+// Door is the subject. Every call says who is knocking and what their password is.
 type Door interface {
-	Open(password string)
+	Open(user, password string) error
+	Lock(user, password string) error
 }
 
 // RealDoor is the actual door to the clubhouse.
 type RealDoor struct{}
 
-func (r *RealDoor) Open(password string) {
-	fmt.Println("Door: Squeak... The door opens. Welcome to the Secret Clubhouse!")
+// NewRealDoor builds the door. Pretend this takes a long time and a lot of wood.
+func NewRealDoor() (*RealDoor, error) {
+	fmt.Println("Door: Building the door (this is expensive!)")
+	return &RealDoor{}, nil
+}
+
+func (r *RealDoor) Open(user, password string) error {
+	fmt.Printf("Door: Squeak... The door opens. Welcome to the Secret Clubhouse, %s!\n", user)
+	return nil
+}
+
+func (r *RealDoor) Lock(user, password string) error {
+	fmt.Printf("Door: Click. %s locked the door.\n", user)
+	return nil
 }
 
 // SecurityProxy is the guard protecting the door.
 type SecurityProxy struct {
-	door *RealDoor
+	guard *guard
+	door  *Lazy[*RealDoor] // not built until someone is actually let in
 }
 
-func (s *SecurityProxy) Open(password string) {
-	if password == "secret123" {
-		fmt.Println("Proxy: Password correct! Opening the door.")
-		if s.door == nil {
-			s.door = &RealDoor{}
-		}
-		s.door.Open(password)
-	} else {
-		fmt.Println("Proxy: WRONG PASSWORD! You cannot enter!")
+func NewSecurityProxy(cfg SecurityConfig) *SecurityProxy {
+	return &SecurityProxy{guard: newGuard(cfg), door: NewLazy(NewRealDoor)}
+}
+
+func (s *SecurityProxy) Open(user, password string) error {
+	if err := s.guard.authorize(OpOpen, user, password); err != nil {
+		fmt.Println("Proxy: STOP!", err)
+		return err
+	}
+	fmt.Println("Proxy: Password correct! Opening the door.")
+	door, err := s.door.Get()
+	if err != nil {
+		return err
 	}
+	return door.Open(user, password)
+}
+
+func (s *SecurityProxy) Lock(user, password string) error {
+	if err := s.guard.authorize(OpLock, user, password); err != nil {
+		fmt.Println("Proxy: STOP!", err)
+		return err
+	}
+	fmt.Println("Proxy: Admin confirmed! Locking the door.")
+	door, err := s.door.Get()
+	if err != nil {
+		return err
+	}
+	return door.Lock(user, password)
 }
 
 func main() {
 	fmt.Println("--- Proxy Pattern: The Security Guard ---")
 
-	var myDoor Door = &SecurityProxy{}
+	club := NewCredentialStore()
+	club.AddUser("alice", "secret123", RoleMember)
+	club.AddUser("bob", "hunter2", RoleAdmin)
+
+	now := time.Now()
+	var myDoor Door = NewSecurityProxy(SecurityConfig{
+		Credentials: club,
+		MaxFailures: 3,
+		Cooldown:    time.Minute,
+		Audit:       slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: dropTime})),
+		Now:         func() time.Time { return now },
+	})
+
+	// 1. Try with wrong password, three times
+	fmt.Println("\nKid: Can I come in? (alice, Password: pizza)")
+	for i := 0; i < 3; i++ {
+		myDoor.Open("alice", "pizza")
+	}
+
+	// 2. Even the correct password doesn't help during the cooldown...
+	fmt.Println("\nKid: Can I come in? (alice, Password: secret123)")
+	myDoor.Open("alice", "secret123")
+
+	// ...but it does once the cooldown is over.
+	now = now.Add(2 * time.Minute)
+	fmt.Println("\nKid (a while later): Can I come in? (alice, Password: secret123)")
+	myDoor.Open("alice", "secret123")
+
+	// 3. Members may open the door, only admins may lock it
+	fmt.Println("\nKid: Can I lock the door? (alice)")
+	myDoor.Lock("alice", "secret123")
+	fmt.Println("\nGrown-up: Can I lock the door? (bob)")
+	myDoor.Lock("bob", "hunter2")
+
+	// 4. The Virtual Proxy: fifty kids knock at once, the door is built exactly once.
+	// The first attempt fails (the wood delivery is late), everybody waiting hears about it,
+	// and the next knock builds it for real.
+	fmt.Println("\n--- Proxy Pattern: The Lazy Door ---")
+	var builds atomic.Int32
+	var lazyDoor Door = NewVirtualDoor(func() (Door, error) {
+		if builds.Add(1) == 1 {
+			time.Sleep(10 * time.Millisecond)
+			return nil, errors.New("wood delivery is late")
+		}
+		return NewRealDoor()
+	})
+	var failed atomic.Int32
+	var knocks sync.WaitGroup
+	everyone := make(chan struct{}) // so all fifty knock while the first build is still going
+	for i := 0; i < 50; i++ {
+		knocks.Add(1)
+		go func() {
+			defer knocks.Done()
+			<-everyone
+			if err := lazyDoor.Open("kid", ""); err != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+	close(everyone)
+	knocks.Wait()
+	fmt.Printf("First rush: %d kids heard \"wood delivery is late\"\n", failed.Load())
+	lazyDoor.Open("alice", "secret123")
+	fmt.Printf("Doors built: %d attempt(s), 1 door\n", builds.Load())
+
+	// 5. The Cache Proxy: a fast memory in front of a slow database
+	fmt.Println("\n--- Proxy Pattern: The Cache ---")
+	db := &SlowMemberDatabase{members: map[string]string{"alice": "member", "bob": "admin"}}
+	cache := NewCachingProxy[string, string](db, CacheConfig{
+		TTL:         time.Minute,
+		NegativeTTL: 5 * time.Second,
+		MaxEntries:  100,
+	})
+	var members Fetcher[string, string] = cache
+	ctx := context.Background()
+
+	// Ten kids ask about alice at the same moment: the database hears the question once.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			members.Fetch(ctx, "alice")
+		}()
+	}
+	wg.Wait()
+
+	role, _ := members.Fetch(ctx, "alice")
+	fmt.Println("alice is a", role)
+	for i := 0; i < 2; i++ {
+		if _, err := members.Fetch(ctx, "mallory"); err != nil {
+			fmt.Println("Lookup failed:", err)
+		}
+	}
+	fmt.Printf("Database queries: %d, proxy stats: %+v\n", db.Queries(), cache.Stats())
 
-	// 1. Try with wrong password
-	fmt.Println("\nKid: Can I come in? (Password: pizza)")
-	myDoor.Open("pizza")
+	// 6. Protective Proxies: a rate limiter and a circuit breaker, both just Doors
+	fmt.Println("\n--- Proxy Pattern: Rate Limiter and Circuit Breaker ---")
+	clock := time.Now()
+	tick := func() time.Time { return clock }
 
-	// 2. Try with correct password
-	fmt.Println("\nKid: Can I come in? (Password: secret123)")
-	myDoor.Open("secret123")
+	limited := NewRateLimitedDoor(&RealDoor{}, RateLimitConfig{Rate: 1, Burst: 2, Now: tick})
+	for i := 0; i < 3; i++ {
+		if err := limited.Open("alice", "secret123"); err != nil {
+			fmt.Println("Rate limiter:", err)
+		}
+	}
+	clock = clock.Add(time.Second) // one knock earned back
+	limited.Open("alice", "secret123")
+	fmt.Printf("Rate limiter metrics: %+v\n", limited.Metrics())
+
+	sticky := &StickyDoor{}
+	sticky.SetJammed(true)
+	breaker := NewCircuitBreakerDoor(sticky, BreakerConfig{FailureThreshold: 2, OpenTimeout: 10 * time.Second, Now: tick})
+	for i := 0; i < 3; i++ {
+		err := breaker.Open("alice", "secret123")
+		fmt.Printf("Breaker (%s): %v\n", breaker.State(), err)
+	}
+	sticky.SetJammed(false)
+	clock = clock.Add(10 * time.Second) // rested long enough to try once more
+	fmt.Printf("Breaker after resting: %s\n", breaker.State())
+	breaker.Open("alice", "secret123")
+	fmt.Printf("Breaker metrics: %+v\n", breaker.Metrics())
+
+	// 7. A generated proxy: DoorProxy (see door_proxy.go) has hook points around every method,
+	// so auth checks, logging and metrics are just functions.
+	fmt.Println("\n--- Proxy Pattern: The Generated Guard ---")
+	calls := map[string]int{}
+	hooked := &DoorProxy{
+		Target: &RealDoor{},
+		Authorize: func(call *DoorCall) error {
+			if call.Args[0] != "alice" {
+				return ErrAccessDenied
+			}
+			return nil
+		},
+		After: func(call *DoorCall) {
+			calls[call.Method]++
+			fmt.Printf("Log: %s%v -> err=%v in %s\n", call.Method, call.Args[:1], call.Err, call.Elapsed.Round(time.Microsecond))
+		},
+	}
+	var generated Door = hooked
+	generated.Open("alice", "secret123")
+	generated.Lock("mallory", "pizza")
+	fmt.Println("Metrics: calls per method", calls)
+
+	// 8. The Reverse Proxy: one front door, three kitchens behind it
+	fmt.Println("\n--- Proxy Pattern: The Reverse Proxy ---")
+	reverseProxyDemo()
+}
+
+func reverseProxyDemo() {
+	var sick atomic.Bool
+	newKitchen := func(name string, isSick *atomic.Bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" && isSick != nil && isSick.Load() {
+				http.Error(w, "sick", http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprint(w, name)
+		}))
+	}
+	kitchens := []*httptest.Server{newKitchen("kitchen-A", nil), newKitchen("kitchen-B", &sick), newKitchen("kitchen-C", nil)}
+	targets := make([]string, len(kitchens))
+	for i, k := range kitchens {
+		defer k.Close()
+		targets[i] = k.URL
+	}
+
+	ask := func(front *httptest.Server, user string) string {
+		req, _ := http.NewRequest(http.MethodGet, front.URL, nil)
+		req.Header.Set("X-User", user)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return resp.Status
+		}
+		return string(body)
+	}
+	serveRound := func(front *httptest.Server, label string) {
+		var served []string
+		for i := 0; i < 6; i++ {
+			served = append(served, ask(front, "kid"))
+		}
+		fmt.Printf("%s: %v\n", label, served)
+	}
+
+	lb, _ := NewLoadBalancer(targets, &RoundRobin{}, HealthCheckConfig{Interval: 20 * time.Millisecond, Fall: 2, Rise: 2})
+	front := httptest.NewServer(lb)
+	defer front.Close()
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	lb.StartHealthChecks(ctx)
+
+	serveRound(front, "Round robin")
+
+	kitchens[2].Close() // kitchen C burns down
+	sick.Store(true)    // kitchen B feels unwell
+	time.Sleep(100 * time.Millisecond)
+	serveRound(front, "After C closes and B is sick")
+
+	sick.Store(false)
+	time.Sleep(100 * time.Millisecond)
+	serveRound(front, "After B recovers")
+
+	// Same user, same kitchen, every time.
+	hashed, _ := NewLoadBalancer(targets[:2], &ConsistentHash{Header: "X-User"}, HealthCheckConfig{})
+	hashedFront := httptest.NewServer(hashed)
+	defer hashedFront.Close()
+	for _, user := range []string{"alice", "bob", "carol", "alice", "bob", "carol"} {
+		fmt.Printf("Consistent hash: %s -> %s\n", user, ask(hashedFront, user))
+	}
+
+	least, _ := NewLoadBalancer(targets[:2], LeastConnections{}, HealthCheckConfig{})
+	leastFront := httptest.NewServer(least)
+	defer leastFront.Close()
+	fmt.Println("Least connections:", ask(leastFront, "kid"))
+}
+
+// dropTime keeps the demo's audit lines short and repeatable.
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.TimeKey && len(groups) == 0 {
+		return slog.Attr{}
+	}
+	return a
 }
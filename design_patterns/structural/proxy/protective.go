@@ -0,0 +1,355 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Protective Proxies
+//
+// Two more guards that look exactly like a Door:
+// - RateLimitedDoor: every kid gets a few knocks, refilled slowly. Knock too fast and you wait outside.
+// - CircuitBreakerDoor: if the door keeps getting stuck, stop yanking on it for a while (open),
+//   then try gently once (half-open), and only go back to normal (closed) if that works.
+// Both take a clock so tests can move time forward instead of sleeping.
+
+var (
+	ErrRateLimited = errors.New("rate limited: too many knocks, slow down")
+	ErrCircuitOpen = errors.New("circuit open: the door is resting")
+)
+
+// -- Rate Limiter --
+
+// RateLimitConfig tunes the RateLimitedDoor. Zero values get sensible defaults.
+// A user who stays away long enough to have a full bucket again is forgotten, so the
+// limiter only remembers users who knocked recently.
+type RateLimitConfig struct {
+	Rate  float64          // knocks per second each user earns back; default 1
+	Burst int              // knocks a user can save up; default 1
+	Now   func() time.Time // clock, for tests; defaults to time.Now
+}
+
+// RateLimitMetrics counts what the limiter did.
+type RateLimitMetrics struct {
+	Allowed  int
+	Rejected int
+	Users    int // buckets currently tracked
+}
+
+type userBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitedDoor gives every user their own token bucket.
+type RateLimitedDoor struct {
+	door Door
+	cfg  RateLimitConfig
+
+	mu        sync.Mutex
+	buckets   map[string]*userBucket
+	lastSweep time.Time
+	metrics   RateLimitMetrics
+}
+
+func NewRateLimitedDoor(door Door, cfg RateLimitConfig) *RateLimitedDoor {
+	if cfg.Rate <= 0 {
+		cfg.Rate = 1
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &RateLimitedDoor{door: door, cfg: cfg, buckets: map[string]*userBucket{}}
+}
+
+// allow takes one token from user's bucket, if there is one.
+func (d *RateLimitedDoor) allow(user string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.cfg.Now()
+	if now.Sub(d.lastSweep) >= d.refillTime() {
+		d.sweep(now)
+	}
+	b := d.buckets[user]
+	if b == nil {
+		b = &userBucket{tokens: float64(d.cfg.Burst), last: now}
+		d.buckets[user] = b
+	}
+	b.tokens = min(float64(d.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*d.cfg.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		d.metrics.Rejected++
+		return false
+	}
+	b.tokens--
+	d.metrics.Allowed++
+	return true
+}
+
+// refillTime is how long an empty bucket takes to fill up again.
+func (d *RateLimitedDoor) refillTime() time.Duration {
+	return time.Duration(float64(d.cfg.Burst) / d.cfg.Rate * float64(time.Second))
+}
+
+// sweep forgets every bucket that has filled up again: a full bucket is exactly what a
+// newcomer gets, so nobody can tell. Runs at most once per refill time. Must hold d.mu.
+func (d *RateLimitedDoor) sweep(now time.Time) {
+	refill := d.refillTime()
+	for user, b := range d.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(d.buckets, user)
+		}
+	}
+	d.lastSweep = now
+}
+
+func (d *RateLimitedDoor) Open(user, password string) error {
+	if !d.allow(user) {
+		return fmt.Errorf("%s: %w", user, ErrRateLimited)
+	}
+	return d.door.Open(user, password)
+}
+
+func (d *RateLimitedDoor) Lock(user, password string) error {
+	if !d.allow(user) {
+		return fmt.Errorf("%s: %w", user, ErrRateLimited)
+	}
+	return d.door.Lock(user, password)
+}
+
+func (d *RateLimitedDoor) Metrics() RateLimitMetrics {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m := d.metrics
+	m.Users = len(d.buckets)
+	return m
+}
+
+// -- Circuit Breaker --
+
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // normal: calls go through
+	BreakerOpen                         // resting: calls fail fast
+	BreakerHalfOpen                     // testing: a few trial calls go through
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerConfig tunes the CircuitBreakerDoor. Zero values get sensible defaults.
+type BreakerConfig struct {
+	FailureThreshold int              // consecutive failures that open the circuit; default 5
+	OpenTimeout      time.Duration    // how long to rest before trying again; default 30s
+	HalfOpenCalls    int              // trial calls allowed at once while half-open; default 1
+	SuccessThreshold int              // trial successes needed to close again; default 1
+	IsFailure        func(error) bool // which errors count against the door; default any error
+	Now              func() time.Time // clock, for tests; defaults to time.Now
+}
+
+// BreakerMetrics counts what the breaker did.
+type BreakerMetrics struct {
+	State        BreakerState
+	Calls        int // calls that reached the door
+	Successes    int
+	Failures     int
+	Rejected     int // calls refused without touching the door
+	StateChanges int
+}
+
+// CircuitBreakerDoor protects a door that sometimes gets stuck.
+type CircuitBreakerDoor struct {
+	door Door
+	cfg  BreakerConfig
+
+	mu                sync.Mutex
+	state             BreakerState
+	failures          int // consecutive, while closed
+	openedAt          time.Time
+	trialsInFlight    int
+	halfOpenSuccesses int
+	generation        int // bumped on every state change, so late results can't confuse a new state
+	metrics           BreakerMetrics
+}
+
+func NewCircuitBreakerDoor(door Door, cfg BreakerConfig) *CircuitBreakerDoor {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenCalls <= 0 {
+		cfg.HalfOpenCalls = 1
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+	if cfg.IsFailure == nil {
+		cfg.IsFailure = func(err error) bool { return err != nil }
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &CircuitBreakerDoor{door: door, cfg: cfg}
+}
+
+func (b *CircuitBreakerDoor) Open(user, password string) error {
+	return b.call(func() error { return b.door.Open(user, password) })
+}
+
+func (b *CircuitBreakerDoor) Lock(user, password string) error {
+	return b.call(func() error { return b.door.Lock(user, password) })
+}
+
+// call runs fn if the breaker allows it. A panicking door counts as a failure, and is
+// recorded before the panic moves on, so a half-open trial slot is never lost.
+func (b *CircuitBreakerDoor) call(fn func() error) (err error) {
+	generation, ok := b.allow()
+	if !ok {
+		return ErrCircuitOpen
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b.record(generation, fmt.Errorf("door panicked: %v", r))
+			panic(r)
+		}
+		b.record(generation, err)
+	}()
+	return fn()
+}
+
+func (b *CircuitBreakerDoor) allow() (generation int, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.cfg.Now().Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+		b.setState(BreakerHalfOpen)
+	}
+	switch b.state {
+	case BreakerOpen:
+		b.metrics.Rejected++
+		return b.generation, false
+	case BreakerHalfOpen:
+		if b.trialsInFlight >= b.cfg.HalfOpenCalls {
+			b.metrics.Rejected++
+			return b.generation, false
+		}
+		b.trialsInFlight++
+	}
+	b.metrics.Calls++
+	return b.generation, true
+}
+
+func (b *CircuitBreakerDoor) record(generation int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := b.cfg.IsFailure(err)
+	if failed {
+		b.metrics.Failures++
+	} else {
+		b.metrics.Successes++
+	}
+
+	if generation != b.generation {
+		return // started before the last state change; its result no longer matters
+	}
+	switch b.state {
+	case BreakerClosed:
+		if !failed {
+			b.failures = 0
+		} else if b.failures++; b.failures >= b.cfg.FailureThreshold {
+			b.setState(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		b.trialsInFlight--
+		if failed {
+			b.setState(BreakerOpen)
+		} else if b.halfOpenSuccesses++; b.halfOpenSuccesses >= b.cfg.SuccessThreshold {
+			b.setState(BreakerClosed)
+		}
+	}
+}
+
+// setState moves to a new state and resets its counters. Must hold b.mu.
+func (b *CircuitBreakerDoor) setState(s BreakerState) {
+	if b.state == s {
+		return
+	}
+	b.state = s
+	b.generation++
+	b.metrics.StateChanges++
+	b.failures, b.halfOpenSuccesses, b.trialsInFlight = 0, 0, 0
+	if s == BreakerOpen {
+		b.openedAt = b.cfg.Now()
+	}
+}
+
+// State reports the current state, noticing if an open circuit has rested long enough.
+func (b *CircuitBreakerDoor) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current()
+}
+
+// current is the state as of now, without changing anything. Must hold b.mu.
+func (b *CircuitBreakerDoor) current() BreakerState {
+	if b.state == BreakerOpen && !b.cfg.Now().Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreakerDoor) Metrics() BreakerMetrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m := b.metrics
+	m.State = b.current()
+	return m
+}
+
+// -- A door that gets stuck --
+
+// ErrDoorStuck is what a StickyDoor says when it won't budge.
+var ErrDoorStuck = errors.New("the door is stuck")
+
+// StickyDoor is a RealDoor that can be jammed.
+type StickyDoor struct {
+	RealDoor
+	mu     sync.Mutex
+	jammed bool
+}
+
+func (s *StickyDoor) SetJammed(jammed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jammed = jammed
+}
+
+func (s *StickyDoor) Open(user, password string) error {
+	s.mu.Lock()
+	jammed := s.jammed
+	s.mu.Unlock()
+	if jammed {
+		fmt.Println("Door: *creak* ...it won't budge.")
+		return ErrDoorStuck
+	}
+	return s.RealDoor.Open(user, password)
+}
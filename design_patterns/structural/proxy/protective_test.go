@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// doorFunc is a Door that does whatever the test says, quietly.
+type doorFunc func() error
+
+func (f doorFunc) Open(user, password string) error { return f() }
+func (f doorFunc) Lock(user, password string) error { return f() }
+
+func openDoor() error { return nil }
+
+func TestRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	d := NewRateLimitedDoor(doorFunc(openDoor), RateLimitConfig{Rate: 2, Burst: 3, Now: clock.Now})
+
+	for i := range 3 {
+		if err := d.Open("alice", ""); err != nil {
+			t.Fatalf("knock %d: %v", i+1, err)
+		}
+	}
+	if err := d.Open("alice", ""); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("4th knock: got %v, want ErrRateLimited", err)
+	}
+	if err := d.Lock("bob", ""); err != nil {
+		t.Fatalf("bob pays for alice: %v", err)
+	}
+
+	clock.Advance(400 * time.Millisecond) // 0.8 of a knock
+	if err := d.Open("alice", ""); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("too early: got %v", err)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := d.Open("alice", ""); err != nil {
+		t.Fatalf("after refilling one knock: %v", err)
+	}
+
+	if m := d.Metrics(); m.Allowed != 5 || m.Rejected != 2 || m.Users != 2 {
+		t.Errorf("metrics %+v", m)
+	}
+}
+
+func TestRateLimitForgetsIdleUsers(t *testing.T) {
+	clock := newFakeClock()
+	d := NewRateLimitedDoor(doorFunc(openDoor), RateLimitConfig{Rate: 1, Burst: 2, Now: clock.Now})
+
+	for i := range 1000 {
+		d.Open(fmt.Sprintf("kid-%d", i), "")
+	}
+	if n := d.Metrics().Users; n != 1000 {
+		t.Fatalf("tracking %d users, want 1000", n)
+	}
+
+	clock.Advance(time.Second) // not full again yet
+	d.Open("alice", "")
+	if n := d.Metrics().Users; n != 1001 {
+		t.Fatalf("forgot users too early: tracking %d", n)
+	}
+
+	clock.Advance(time.Second) // the kids are full again, alice isn't
+	d.Open("bob", "")
+	if n := d.Metrics().Users; n != 2 {
+		t.Errorf("tracking %d users after the kids went quiet, want alice and bob", n)
+	}
+
+	// Being forgotten doesn't give anyone extra knocks.
+	d.Open("bob", "")
+	if err := d.Open("bob", ""); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("bob's 3rd knock: got %v, want ErrRateLimited", err)
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	clock := newFakeClock()
+	stuck := true
+	door := doorFunc(func() error {
+		if stuck {
+			return ErrDoorStuck
+		}
+		return nil
+	})
+	b := NewCircuitBreakerDoor(door, BreakerConfig{FailureThreshold: 3, OpenTimeout: 10 * time.Second, SuccessThreshold: 2, Now: clock.Now})
+
+	for range 3 {
+		if err := b.Open("alice", ""); !errors.Is(err, ErrDoorStuck) {
+			t.Fatalf("got %v, want ErrDoorStuck", err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state %v after 3 failures", b.State())
+	}
+	if err := b.Open("alice", ""); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("while open: got %v", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %v after resting", b.State())
+	}
+	if err := b.Open("alice", ""); !errors.Is(err, ErrDoorStuck) {
+		t.Fatalf("failed trial: got %v", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("a failed trial left the breaker %v", b.State())
+	}
+
+	clock.Advance(10 * time.Second)
+	stuck = false
+	for range 2 {
+		if err := b.Open("alice", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("state %v after 2 good trials", b.State())
+	}
+	m := b.Metrics()
+	if m.Calls != 6 || m.Failures != 4 || m.Successes != 2 || m.Rejected != 1 || m.StateChanges != 5 {
+		t.Errorf("metrics %+v", m)
+	}
+}
+
+func TestBreakerLimitsTrialsInFlight(t *testing.T) {
+	clock := newFakeClock()
+	var b *CircuitBreakerDoor
+	var inner error
+	door := doorFunc(func() error {
+		// While the first trial is still going, a second one must be turned away.
+		inner = b.Open("bob", "")
+		return nil
+	})
+	b = NewCircuitBreakerDoor(door, BreakerConfig{FailureThreshold: 1, Now: clock.Now})
+	b.state, b.openedAt = BreakerOpen, clock.Now()
+	clock.Advance(30 * time.Second)
+
+	if err := b.Open("alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(inner, ErrCircuitOpen) {
+		t.Errorf("second trial: got %v, want ErrCircuitOpen", inner)
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("state %v", b.State())
+	}
+}
+
+func TestBreakerSurvivesPanickingDoor(t *testing.T) {
+	clock := newFakeClock()
+	panics := true
+	door := doorFunc(func() error {
+		if panics {
+			panic("the hinge fell off")
+		}
+		return nil
+	})
+	b := NewCircuitBreakerDoor(door, BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second, Now: clock.Now})
+
+	open := func() (err error, panicked bool) {
+		defer func() {
+			if recover() != nil {
+				panicked = true
+			}
+		}()
+		return b.Open("alice", ""), false
+	}
+
+	if _, panicked := open(); !panicked {
+		t.Fatal("the panic was swallowed")
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("a panic didn't count as a failure: %v", b.State())
+	}
+
+	clock.Advance(time.Second)
+	if _, panicked := open(); !panicked { // the half-open trial panics too
+		t.Fatal("the panic was swallowed")
+	}
+	clock.Advance(time.Second)
+	panics = false
+	// The trial slot must have been given back, or this is rejected forever.
+	if err, _ := open(); err != nil {
+		t.Fatalf("got %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("state %v", b.State())
+	}
+}
+
+func TestBreakerIsFailure(t *testing.T) {
+	denied := doorFunc(func() error { return ErrAccessDenied })
+	b := NewCircuitBreakerDoor(denied, BreakerConfig{
+		FailureThreshold: 1,
+		IsFailure:        func(err error) bool { return err != nil && !errors.Is(err, ErrAccessDenied) },
+		Now:              newFakeClock().Now,
+	})
+	for range 5 {
+		b.Open("mallory", "guess")
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("wrong passwords opened the circuit: %v", b.State())
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gostudy/design_patterns/structural/internal/codegen"
+)
+
+// proxygen
+//
+// 5-Year-Old Explanation:
+// Every new guard we hire needs the same training: "Check who it is. Write it in the notebook.
+// Count how many kids came. Then let them talk to the door."
+// This robot reads the Door interface and builds a guard with empty slots for each of those jobs,
+// so you only have to say WHAT to check, not copy the whole guard again.
+//
+// Real World Scenario:
+// Cross-cutting concerns (auth, logging, metrics, recording calls for tests) for any service
+// interface, without hand-writing a SecurityProxy clone per interface.
+//
+// Usage (from a go:generate line in the package that declares the interface):
+//
+//	//go:generate go run ./proxygen -type Door
+//
+// For an interface named X it writes x_proxy.go containing:
+//   - XCall: one call's method name, arguments, results, error and timing.
+//   - XProxy: forwards every method to Target with Authorize, Before and After hooks.
+//     If Authorize returns an error the Target is never called; the method returns it as
+//     its error result, the other results are zero values. After runs even when Target panics.
+//
+// -authorize lists the methods Authorize may refuse (default: all of them). A refusal has to
+// reach the caller somehow, so naming a method without an error result is an error.
+
+var (
+	typeName  = flag.String("type", "", "name of the interface to proxy (required)")
+	output    = flag.String("output", "", "output file name; default <type>_proxy.go")
+	dir       = flag.String("dir", ".", "directory of the package that declares the interface")
+	authorize = flag.String("authorize", "*", "comma-separated methods Authorize may refuse; * for all, empty for none")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("proxygen: ")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_proxy.go"
+	}
+
+	var methods []string
+	if *authorize != "" {
+		methods = strings.Split(*authorize, ",")
+	}
+	src, err := Generate(*dir, *typeName, filepath.Base(*output), methods)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Generate type-checks the package in dir and returns the formatted proxy source for the
+// named interface. The file called skip (usually the previous output) is left out.
+// authorize names the methods Authorize may refuse; "*" stands for all of them.
+func Generate(dir, name, skip string, authorize []string) ([]byte, error) {
+	pkg, err := codegen.LoadPackage(dir, skip, name+"Proxy", name+"Call")
+	if err != nil {
+		return nil, err
+	}
+	iface, err := codegen.LookupInterface(pkg, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		switch m := iface.Method(i).Name(); m {
+		case "Target", "Authorize", "Before", "After", "finish":
+			return nil, fmt.Errorf("method %s.%s clashes with a proxy field", name, m)
+		}
+	}
+
+	refusable, err := refusableMethods(name, iface, authorize)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{File: codegen.NewFile(pkg), refusable: refusable}
+	g.Import("time", "time") // first, so the hand-written time.Now() keeps meaning package time
+	g.emit(name, iface)
+	return g.Source("proxygen")
+}
+
+var errorType = types.Universe.Lookup("error").Type()
+
+func returnsError(sig *types.Signature) bool {
+	results := sig.Results()
+	return results.Len() > 0 && types.Identical(results.At(results.Len()-1).Type(), errorType)
+}
+
+// refusableMethods checks the -authorize list against the interface. A method without an
+// error result would have to swallow a refusal and hand back zero values as if it had run.
+func refusableMethods(name string, iface *types.Interface, authorize []string) (map[string]bool, error) {
+	refusable := map[string]bool{}
+	for _, m := range authorize {
+		m = strings.TrimSpace(m)
+		found := false
+		for i := 0; i < iface.NumMethods(); i++ {
+			if f := iface.Method(i); m == "*" || f.Name() == m {
+				if !returnsError(f.Type().(*types.Signature)) {
+					return nil, fmt.Errorf("-authorize: %s.%s has no error result to report a refusal with", name, f.Name())
+				}
+				refusable[f.Name()] = true
+				found = true
+			}
+		}
+		if !found && m != "*" {
+			return nil, fmt.Errorf("-authorize: %s has no method %s", name, m)
+		}
+	}
+	return refusable, nil
+}
+
+type generator struct {
+	*codegen.File
+	refusable map[string]bool // methods Authorize may refuse
+}
+
+func (g *generator) emit(name string, iface *types.Interface) {
+	proxy, call := name+"Proxy", name+"Call"
+
+	g.Printf("// %s describes one call going through a %s.\n", call, proxy)
+	g.Printf("type %s struct {\n", call)
+	g.Printf("Method string\n")
+	g.Printf("Args []any\n")
+	var refusable []string
+	for i := 0; i < iface.NumMethods(); i++ {
+		if m := iface.Method(i).Name(); g.refusable[m] {
+			refusable = append(refusable, m)
+		}
+	}
+	g.Printf("Results []any // what the caller gets back, set before After runs\n")
+	if len(refusable) > 0 {
+		g.Printf("Err error // the call's error result, or Authorize's refusal\n")
+	} else {
+		g.Printf("Err error // the call's error result\n")
+	}
+	g.Printf("Panic any // what Target panicked with, if it did; the panic goes on after After\n")
+	g.Printf("Start time.Time\n")
+	g.Printf("Elapsed time.Duration\n")
+	g.Printf("}\n\n")
+
+	g.Printf("// %s forwards every %s method to Target, with optional hooks around each call:\n", proxy, name)
+	g.Printf("// Before runs just before Target, After runs last (even when refused or panicking).\n")
+	if len(refusable) > 0 {
+		g.Printf("// Authorize can refuse calls to %s.\n", strings.Join(refusable, ", "))
+	}
+	g.Printf("type %s struct {\n", proxy)
+	g.Printf("Target %s\n", name)
+	if len(refusable) > 0 {
+		g.Printf("Authorize func(call *%s) error\n", call)
+	}
+	g.Printf("Before func(call *%s)\n", call)
+	g.Printf("After func(call *%s)\n", call)
+	g.Printf("}\n\n")
+
+	// Deferred by every method, with whatever recover() returned.
+	g.Printf("func (p *%s) finish(call *%s, panicked any) {\n", proxy, call)
+	g.Printf("call.Elapsed = time.Since(call.Start)\n")
+	g.Printf("call.Panic = panicked\n")
+	g.Printf("if p.After != nil {\n")
+	g.Printf("p.After(call)\n")
+	g.Printf("}\n")
+	g.Printf("if panicked != nil {\n")
+	g.Printf("panic(panicked)\n")
+	g.Printf("}\n")
+	g.Printf("}\n\n")
+
+	for i := 0; i < iface.NumMethods(); i++ {
+		g.emitMethod(proxy, call, iface.Method(i))
+	}
+}
+
+func (g *generator) emitMethod(proxy, call string, m *types.Func) {
+	sig := m.Type().(*types.Signature)
+	decl, args, fwd := g.Params(sig)
+	rtypes, rnames, _ := g.Results(sig)
+	named := make([]string, len(rnames))
+	for i := range rnames {
+		named[i] = rnames[i] + " " + rtypes[i]
+	}
+	rlist := ""
+	if len(named) > 0 {
+		rlist = "(" + strings.Join(named, ", ") + ")"
+	}
+
+	g.Printf("func (p *%s) %s(%s) %s {\n", proxy, m.Name(), strings.Join(decl, ", "), rlist)
+	g.Printf("call := &%s{Method: %q, Args: []any{%s}, Start: time.Now()}\n", call, m.Name(), strings.Join(args, ", "))
+
+	// The results are named so the deferred func sees what the caller gets, however the
+	// method ends: refused, returned, or panicking (with zero values).
+	g.Printf("defer func() {\n")
+	if len(rnames) > 0 {
+		g.Printf("call.Results = []any{%s}\n", strings.Join(rnames, ", "))
+		if returnsError(sig) {
+			g.Printf("call.Err = %s\n", rnames[len(rnames)-1])
+		}
+	}
+	g.Printf("p.finish(call, recover())\n")
+	g.Printf("}()\n")
+
+	// Refused: skip Target, return zero values and the refusal.
+	if g.refusable[m.Name()] {
+		g.Printf("if p.Authorize != nil {\n")
+		g.Printf("if err := p.Authorize(call); err != nil {\n")
+		g.Printf("%s = err\n", rnames[len(rnames)-1])
+		g.Printf("return\n")
+		g.Printf("}\n")
+		g.Printf("}\n")
+	}
+
+	g.Printf("if p.Before != nil {\n")
+	g.Printf("p.Before(call)\n")
+	g.Printf("}\n")
+	invoke := fmt.Sprintf("p.Target.%s(%s)", m.Name(), strings.Join(fwd, ", "))
+	if len(rnames) == 0 {
+		g.Printf("%s\n", invoke)
+	} else {
+		g.Printf("return %s\n", invoke)
+	}
+	g.Printf("}\n\n")
+}
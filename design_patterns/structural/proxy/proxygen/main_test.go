@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gostudy/design_patterns/structural/internal/codegen"
+)
+
+const fixture = "testdata/fixture"
+
+func TestGenerateCompiles(t *testing.T) {
+	src, err := Generate(fixture, "Store", "store_proxy.go", []string{"Get", "Put", "Render", "Close"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		// Variadics are declared with ... and forwarded with ...
+		"func (p *StoreProxy) Put(a0 context.Context, a1 string, a2 ...[]byte) (r0 error)",
+		"return p.Target.Put(a0, a1, a2...)",
+		"func (p *StoreProxy) Keys(a0 ...string) (r0 []string)",
+		// Several results, the last one an error: refused calls return zeros and the refusal.
+		"func (p *StoreProxy) Get(a0 context.Context, a1 string) (r0 []byte, r1 time.Time, r2 error)",
+		"r2 = err",
+		"call.Results = []any{r0, r1, r2}",
+		"call.Err = r2",
+		// Several results and no error.
+		"func (p *StoreProxy) Stat(a0 string) (r0 int64, r1 time.Time)",
+		// Two packages called template: the parameter's comes first and keeps the name.
+		`template2 "text/template"`,
+		"func (p *StoreProxy) Render(a0 *template.Template) (r0 *template2.Template, r1 bool, r2 error)",
+		// Nothing at all.
+		"func (p *StoreProxy) Touch() {",
+		"Authorize can refuse calls to Close, Get, Put, Render.",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("output is missing %q:\n%s", want, src)
+		}
+	}
+	if n := strings.Count(string(src), "p.Authorize(call)"); n != 4 {
+		t.Errorf("%d methods can be refused, want 4:\n%s", n, src)
+	}
+
+	if err := codegen.Compile(fixture, map[string][]byte{"store_proxy.go": src}); err != nil {
+		t.Fatalf("generated code doesn't compile: %v\n%s", err, src)
+	}
+}
+
+func TestGenerateWithoutAuthorize(t *testing.T) {
+	src, err := Generate(fixture, "Store", "store_proxy.go", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), "Authorize") {
+		t.Errorf("a proxy that may refuse nothing has an Authorize hook:\n%s", src)
+	}
+	if err := codegen.Compile(fixture, map[string][]byte{"store_proxy.go": src}); err != nil {
+		t.Fatalf("generated code doesn't compile: %v\n%s", err, src)
+	}
+}
+
+func TestGenerateRejects(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		authorize []string
+		want      string
+	}{
+		{"Missing", nil, "not found"},
+		{"NotAnInterface", nil, "not an interface"},
+		{"Clashing", nil, "clashes with a proxy field"},
+		// A refusal can't reach the caller of Keys, so it must not be refusable.
+		{"Store", []string{"*"}, "Store.Keys has no error result"},
+		{"Store", []string{"Get", "Touch"}, "Store.Touch has no error result"},
+		{"Store", []string{"Gte"}, "Store has no method Gte"},
+	} {
+		if _, err := Generate(fixture, tt.name, "check.go", tt.authorize); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Generate(%s, %q) = %v, want an error containing %q", tt.name, tt.authorize, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+package fixture
+
+// Doesn't compile until the proxy exists, just like a real package using it.
+var _ Store = (*StoreProxy)(nil)
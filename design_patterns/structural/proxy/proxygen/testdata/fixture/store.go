@@ -0,0 +1,26 @@
+package fixture
+
+import (
+	"context"
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+	"time"
+)
+
+// Store has a bit of everything the generator has to get right.
+type Store interface {
+	io.Closer
+	Get(ctx context.Context, key string) ([]byte, time.Time, error)
+	Put(ctx context.Context, key string, values ...[]byte) error
+	Keys(prefixes ...string) []string
+	Stat(key string) (size int64, modified time.Time)
+	Render(t *htmltemplate.Template) (*template.Template, bool, error)
+	Touch()
+}
+
+type NotAnInterface struct{}
+
+type Clashing interface {
+	Authorize() error
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reverse Proxy with Load Balancing
+//
+// The most common proxy in production: clients talk to ONE address, and the proxy quietly
+// forwards each request to one of several identical backend servers.
+// - A Balancer decides which backend gets the next request.
+// - Health checks keep poking every backend; sick ones are taken out of rotation and
+//   put back once they get better.
+
+var ErrHealthChecksRunning = errors.New("health checks are already running")
+
+// Backend is one upstream server.
+type Backend struct {
+	URL     *url.URL
+	proxy   *httputil.ReverseProxy
+	active  atomic.Int64 // requests in flight right now
+	healthy atomic.Bool  // read freely, written under mu
+
+	mu        sync.Mutex
+	fails     int // failed requests and checks in a row
+	passes    int // passed checks in a row; only counts while ejected
+	ejectedAt time.Time
+}
+
+func (b *Backend) Healthy() bool      { return b.healthy.Load() }
+func (b *Backend) ActiveConns() int64 { return b.active.Load() }
+
+// failed counts a failed request or check, and reports whether that ejected b.
+func (b *Backend) failed(fall int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.passes = 0
+	b.fails++
+	if !b.Healthy() || b.fails < fall {
+		return false
+	}
+	b.healthy.Store(false)
+	b.ejectedAt = now
+	return true
+}
+
+// answered forgets earlier failures once a request got through.
+func (b *Backend) answered() {
+	b.mu.Lock()
+	b.fails = 0
+	b.mu.Unlock()
+}
+
+// passed counts a passed check, and reports whether that re-admitted b.
+func (b *Backend) passed(rise int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fails = 0
+	if b.Healthy() {
+		// Only passes since the last ejection count, whoever ejected it. Otherwise a
+		// backend ejected by failed requests would be back after a single check.
+		b.passes = 0
+		return false
+	}
+	b.passes++
+	if b.passes < rise {
+		return false
+	}
+	b.readmit()
+	return true
+}
+
+// cooledDown re-admits b if it has been out for at least cooldown.
+func (b *Backend) cooledDown(now time.Time, cooldown time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Healthy() || now.Sub(b.ejectedAt) < cooldown {
+		return false
+	}
+	b.readmit()
+	return true
+}
+
+// readmit puts b back with a clean slate. Must hold b.mu.
+func (b *Backend) readmit() {
+	b.healthy.Store(true)
+	b.fails, b.passes = 0, 0
+}
+
+// Balancer picks a backend from the healthy ones. backends is never empty.
+type Balancer interface {
+	Pick(r *http.Request, backends []*Backend) *Backend
+}
+
+// -- Balancing Strategies --
+
+// RoundRobin takes turns.
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+func (rr *RoundRobin) Pick(r *http.Request, backends []*Backend) *Backend {
+	n := rr.next.Add(1) - 1
+	return backends[n%uint64(len(backends))]
+}
+
+// LeastConnections picks the backend with the fewest requests in flight.
+type LeastConnections struct{}
+
+func (LeastConnections) Pick(r *http.Request, backends []*Backend) *Backend {
+	best := backends[0]
+	for _, b := range backends[1:] {
+		if b.ActiveConns() < best.ActiveConns() {
+			best = b
+		}
+	}
+	return best
+}
+
+// ConsistentHash sends every request with the same header value to the same backend,
+// e.g. Header: "X-User" keeps each user's session on one server. When a backend leaves,
+// only the users that were on it move; everyone else stays put.
+type ConsistentHash struct {
+	Header   string
+	Replicas int // virtual nodes per backend; default 100
+
+	mu      sync.Mutex
+	ringKey string
+	ring    []ringPoint
+}
+
+type ringPoint struct {
+	hash    uint32
+	backend *Backend
+}
+
+func (c *ConsistentHash) Pick(r *http.Request, backends []*Backend) *Backend {
+	ring := c.ringFor(backends)
+	h := crc32.ChecksumIEEE([]byte(r.Header.Get(c.Header)))
+	i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	if i == len(ring) {
+		i = 0 // wrap around the ring
+	}
+	return ring[i].backend
+}
+
+// ringFor builds (and caches) the hash ring for this particular set of backends.
+func (c *ConsistentHash) ringFor(backends []*Backend) []ringPoint {
+	names := make([]string, len(backends))
+	for i, b := range backends {
+		names[i] = b.URL.String()
+	}
+	key := strings.Join(names, ",")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key == c.ringKey {
+		return c.ring
+	}
+	replicas := c.Replicas
+	if replicas <= 0 {
+		replicas = 100
+	}
+	ring := make([]ringPoint, 0, len(backends)*replicas)
+	for _, b := range backends {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "#" + b.URL.String()))
+			ring = append(ring, ringPoint{hash: h, backend: b})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	c.ringKey, c.ring = key, ring
+	return ring
+}
+
+// -- The Proxy --
+
+// HealthCheckConfig tunes how backends are ejected and re-admitted. Zero values get
+// sensible defaults.
+type HealthCheckConfig struct {
+	Path     string           // default "/healthz"
+	Interval time.Duration    // default 1s
+	Timeout  time.Duration    // default 500ms
+	Fall     int              // consecutive failed requests or checks before ejecting; default 2
+	Rise     int              // consecutive passed checks before re-admitting; default 2
+	Cooldown time.Duration    // without health checks, how long an ejected backend sits out; default 30s
+	Now      func() time.Time // clock, for tests; defaults to time.Now
+}
+
+// LoadBalancer is an http.Handler that proxies to a pool of backends.
+type LoadBalancer struct {
+	backends []*Backend
+	balancer Balancer
+	cfg      HealthCheckConfig
+	checking atomic.Bool // health checks are running and decide who comes back
+}
+
+func NewLoadBalancer(targets []string, balancer Balancer, cfg HealthCheckConfig) (*LoadBalancer, error) {
+	if cfg.Path == "" {
+		cfg.Path = "/healthz"
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 500 * time.Millisecond
+	}
+	if cfg.Fall <= 0 {
+		cfg.Fall = 2
+	}
+	if cfg.Rise <= 0 {
+		cfg.Rise = 2
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 30 * time.Second
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	lb := &LoadBalancer{balancer: balancer, cfg: cfg}
+	for _, t := range targets {
+		u, err := url.Parse(t)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", t, err)
+		}
+		b := &Backend{URL: u, proxy: httputil.NewSingleHostReverseProxy(u)}
+		b.healthy.Store(true)
+		// Passive check: requests that can't reach the backend count toward Fall just
+		// like failed health checks, and one that gets an answer starts the count over.
+		// A client that hung up or ran out of time says nothing about the backend.
+		b.proxy.ModifyResponse = func(*http.Response) error {
+			b.answered()
+			return nil
+		}
+		b.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() != nil {
+				return // nobody is left to answer
+			}
+			if b.failed(cfg.Fall, cfg.Now()) {
+				fmt.Printf("Proxy: %s is not answering, ejecting it\n", b.URL.Host)
+			}
+			http.Error(w, "bad gateway: "+err.Error(), http.StatusBadGateway)
+		}
+		lb.backends = append(lb.backends, b)
+	}
+	return lb, nil
+}
+
+// Backends lists every backend, healthy or not.
+func (lb *LoadBalancer) Backends() []*Backend {
+	return lb.backends
+}
+
+// healthyBackends lists the backends in rotation. Without health checks nobody else would
+// ever bring an ejected backend back, so one that has sat out the cooldown gets another try.
+func (lb *LoadBalancer) healthyBackends() []*Backend {
+	var healthy []*Backend
+	now := lb.cfg.Now()
+	for _, b := range lb.backends {
+		if !lb.checking.Load() && b.cooledDown(now, lb.cfg.Cooldown) {
+			fmt.Printf("Proxy: %s sat out %s, trying it again\n", b.URL.Host, lb.cfg.Cooldown)
+		}
+		if b.Healthy() {
+			healthy = append(healthy, b)
+		}
+	}
+	return healthy
+}
+
+func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	healthy := lb.healthyBackends()
+	if len(healthy) == 0 {
+		http.Error(w, "no healthy backends", http.StatusServiceUnavailable)
+		return
+	}
+	b := lb.balancer.Pick(r, healthy)
+	b.active.Add(1)
+	defer b.active.Add(-1)
+	b.proxy.ServeHTTP(w, r)
+}
+
+// StartHealthChecks polls every backend until ctx is cancelled. Only one set of checks
+// runs at a time; starting another returns ErrHealthChecksRunning.
+func (lb *LoadBalancer) StartHealthChecks(ctx context.Context) error {
+	if !lb.checking.CompareAndSwap(false, true) {
+		return ErrHealthChecksRunning
+	}
+	client := &http.Client{Timeout: lb.cfg.Timeout}
+
+	go func() {
+		defer lb.checking.Store(false)
+		ticker := time.NewTicker(lb.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, b := range lb.backends {
+					lb.check(ctx, client, b)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (lb *LoadBalancer) check(ctx context.Context, client *http.Client, b *Backend) {
+	ok := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL.JoinPath(lb.cfg.Path).String(), nil)
+	if err == nil {
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			ok = resp.StatusCode < 400
+		}
+	}
+	if ctx.Err() != nil {
+		return // stopped mid-check; that says nothing about the backend
+	}
+
+	if ok {
+		if b.passed(lb.cfg.Rise) {
+			fmt.Printf("Health: %s is back, re-admitting it\n", b.URL.Host)
+		}
+		return
+	}
+	if b.failed(lb.cfg.Fall, lb.cfg.Now()) {
+		fmt.Printf("Health: %s keeps failing, ejecting it\n", b.URL.Host)
+	}
+}
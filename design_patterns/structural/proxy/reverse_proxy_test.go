@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBackend answers its own name, and can be told to fail health checks or to hang up.
+type testBackend struct {
+	*httptest.Server
+	name     string
+	sick     atomic.Bool // fail /healthz
+	hangUp   atomic.Bool // drop the connection on every request
+	checks   atomic.Int32
+	requests atomic.Int32
+}
+
+func newTestBackend(t *testing.T, name string) *testBackend {
+	t.Helper()
+	b := &testBackend{name: name}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			b.checks.Add(1)
+			if b.sick.Load() {
+				http.Error(w, "sick", http.StatusInternalServerError)
+			}
+			return
+		}
+		b.requests.Add(1)
+		if b.hangUp.Load() {
+			panic(http.ErrAbortHandler)
+		}
+		fmt.Fprint(w, name)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func newTestPool(t *testing.T, n int, balancer Balancer, cfg HealthCheckConfig) (*LoadBalancer, []*testBackend) {
+	t.Helper()
+	backends := make([]*testBackend, n)
+	targets := make([]string, n)
+	for i := range backends {
+		backends[i] = newTestBackend(t, string(rune('A'+i)))
+		targets[i] = backends[i].URL
+	}
+	lb, err := NewLoadBalancer(targets, balancer, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lb, backends
+}
+
+func get(ctx context.Context, lb http.Handler, user string) (int, string) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("X-User", user)
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, req)
+	body, _ := io.ReadAll(rec.Body)
+	return rec.Code, string(body)
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	lb, _ := newTestPool(t, 3, &RoundRobin{}, HealthCheckConfig{})
+	var got string
+	for range 6 {
+		_, body := get(context.Background(), lb, "")
+		got += body
+	}
+	if got != "ABCABC" {
+		t.Errorf("served by %s", got)
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	lb, _ := newTestPool(t, 3, LeastConnections{}, HealthCheckConfig{})
+	bs := lb.Backends()
+	bs[0].active.Store(2)
+	bs[1].active.Store(1)
+	bs[2].active.Store(3)
+	if b := (LeastConnections{}).Pick(nil, bs); b != bs[1] {
+		t.Errorf("picked %s", b.URL)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	lb, _ := newTestPool(t, 3, &ConsistentHash{Header: "X-User"}, HealthCheckConfig{})
+	bs := lb.Backends()
+	ch := &ConsistentHash{Header: "X-User"}
+	pick := func(user string, backends []*Backend) *Backend {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-User", user)
+		return ch.Pick(r, backends)
+	}
+
+	moved, stayed := 0, 0
+	for i := range 200 {
+		user := fmt.Sprintf("user-%d", i)
+		before := pick(user, bs)
+		if again := pick(user, bs); again != before {
+			t.Fatalf("%s moved without any change", user)
+		}
+		after := pick(user, bs[:2]) // C leaves
+		switch {
+		case before == bs[2]:
+			moved++
+		case after != before:
+			t.Errorf("%s moved from %s although its backend stayed", user, before.URL)
+		default:
+			stayed++
+		}
+	}
+	if moved == 0 || stayed == 0 {
+		t.Errorf("moved %d, stayed %d: the ring is lopsided", moved, stayed)
+	}
+}
+
+func TestKilledBackendIsEjected(t *testing.T) {
+	lb, backends := newTestPool(t, 3, &RoundRobin{}, HealthCheckConfig{Interval: 10 * time.Millisecond, Timeout: time.Second})
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := lb.StartHealthChecks(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if code, _ := get(context.Background(), lb, ""); code != http.StatusOK {
+			t.Fatalf("status %d before anything broke", code)
+		}
+	}
+
+	backends[2].Close() // C dies mid-test
+	failures := 0
+	for range 30 {
+		code, body := get(context.Background(), lb, "")
+		if code != http.StatusOK {
+			failures++
+			continue
+		}
+		if body == "C" {
+			t.Fatal("served by a dead backend")
+		}
+	}
+	// The requests that found C dead eject it (Fall is 2); nobody else should notice.
+	if failures > 2 {
+		t.Errorf("%d requests failed after C died, want at most 2", failures)
+	}
+	time.Sleep(50 * time.Millisecond) // several health checks
+	if lb.Backends()[2].Healthy() {
+		t.Error("a dead backend was re-admitted")
+	}
+}
+
+func TestSickBackendFallsAndRises(t *testing.T) {
+	lb, backends := newTestPool(t, 2, &RoundRobin{}, HealthCheckConfig{Interval: 10 * time.Millisecond, Fall: 2, Rise: 2})
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := lb.StartHealthChecks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b := lb.Backends()[1]
+
+	backends[1].sick.Store(true)
+	eventually(t, "B is ejected", func() bool { return !b.Healthy() })
+	for range 4 {
+		if _, body := get(context.Background(), lb, ""); body != "A" {
+			t.Fatalf("served by %q while B is out", body)
+		}
+	}
+
+	backends[1].sick.Store(false)
+	eventually(t, "B is back", b.Healthy)
+}
+
+func TestPassiveEjectionHonorsRise(t *testing.T) {
+	lb, backends := newTestPool(t, 2, &RoundRobin{}, HealthCheckConfig{Interval: 10 * time.Millisecond, Rise: 3})
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := lb.StartHealthChecks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b := lb.Backends()[1]
+
+	// Let B pass plenty of checks while healthy; those mustn't count later.
+	eventually(t, "B passes some checks", func() bool { return backends[1].checks.Load() >= 5 })
+
+	backends[1].hangUp.Store(true)
+	for b.Healthy() {
+		get(context.Background(), lb, "")
+	}
+	checksAtEjection := backends[1].checks.Load()
+	backends[1].hangUp.Store(false)
+
+	eventually(t, "B is back", b.Healthy)
+	// One check may already have been on its way when B was ejected; all others count.
+	if n := backends[1].checks.Load() - checksAtEjection; n < 2 {
+		t.Errorf("re-admitted after %d checks, Rise is 3", n)
+	}
+}
+
+func TestClientHangingUpDoesNotEject(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	lb, err := NewLoadBalancer([]string{slow.URL}, &RoundRobin{}, HealthCheckConfig{Fall: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hangUp, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	get(hangUp, lb, "")
+
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	get(timeout, lb, "")
+
+	if !lb.Backends()[0].Healthy() {
+		t.Error("a client giving up ejected the backend")
+	}
+}
+
+func TestPassiveFailuresCountTowardFall(t *testing.T) {
+	lb, backends := newTestPool(t, 1, &RoundRobin{}, HealthCheckConfig{Fall: 3})
+	b := lb.Backends()[0]
+
+	backends[0].hangUp.Store(true)
+	for range 2 {
+		if code, _ := get(context.Background(), lb, ""); code != http.StatusBadGateway {
+			t.Fatalf("status %d, want 502", code)
+		}
+	}
+	backends[0].hangUp.Store(false)
+	if code, _ := get(context.Background(), lb, ""); code != http.StatusOK {
+		t.Fatalf("status %d, want 200", code)
+	}
+
+	// The answer started the count over, so it takes three more failures in a row.
+	backends[0].hangUp.Store(true)
+	for i := range 3 {
+		if !b.Healthy() {
+			t.Fatalf("ejected after %d failures in a row, Fall is 3", i)
+		}
+		get(context.Background(), lb, "")
+	}
+	if b.Healthy() {
+		t.Error("still in rotation after 3 failures in a row")
+	}
+}
+
+func TestNoHealthyBackends(t *testing.T) {
+	clock := newFakeClock()
+	lb, backends := newTestPool(t, 1, &RoundRobin{}, HealthCheckConfig{Fall: 1, Cooldown: time.Minute, Now: clock.Now})
+	backends[0].hangUp.Store(true)
+	if code, _ := get(context.Background(), lb, ""); code != http.StatusBadGateway {
+		t.Errorf("first request: status %d, want 502", code)
+	}
+	if code, _ := get(context.Background(), lb, ""); code != http.StatusServiceUnavailable {
+		t.Errorf("after ejection: status %d, want 503", code)
+	}
+
+	// With no health checks running, the backend gets another try after the cooldown.
+	backends[0].hangUp.Store(false)
+	clock.Advance(time.Minute - time.Second)
+	if code, _ := get(context.Background(), lb, ""); code != http.StatusServiceUnavailable {
+		t.Errorf("a second before the cooldown ends: status %d, want 503", code)
+	}
+	clock.Advance(time.Second)
+	if code, body := get(context.Background(), lb, ""); code != http.StatusOK || body != "A" {
+		t.Errorf("after the cooldown: status %d %q, want 200 from A", code, body)
+	}
+}
+
+func TestCooldownWaitsForRunningHealthChecks(t *testing.T) {
+	clock := newFakeClock()
+	lb, backends := newTestPool(t, 1, &RoundRobin{}, HealthCheckConfig{Interval: time.Hour, Fall: 1, Cooldown: time.Minute, Now: clock.Now})
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	if err := lb.StartHealthChecks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := lb.StartHealthChecks(ctx); !errors.Is(err, ErrHealthChecksRunning) {
+		t.Fatalf("second StartHealthChecks: got %v, want ErrHealthChecksRunning", err)
+	}
+
+	backends[0].hangUp.Store(true)
+	get(context.Background(), lb, "")
+	backends[0].hangUp.Store(false)
+	clock.Advance(time.Hour)
+	if code, _ := get(context.Background(), lb, ""); code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503: the health checks decide when it comes back", code)
+	}
+
+	stop()
+	eventually(t, "health checks can start again", func() bool {
+		return lb.StartHealthChecks(context.Background()) == nil
+	})
+}
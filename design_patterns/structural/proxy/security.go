@@ -0,0 +1,259 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Security Guard Toolkit
+//
+// A real guard doesn't keep the password on a sticky note ("secret123").
+// - Passwords are stored as salted hashes, and compared in constant time so
+//   nobody can guess them by timing how fast the guard says "no".
+// - Too many wrong guesses and that kid has to wait outside for a while (lockout).
+// - Members may open the door, but only admins may lock it (roles per operation).
+// - Every attempt goes into the guard's notebook (a structured audit log).
+
+var (
+	ErrAccessDenied = errors.New("access denied: wrong user or password")
+	ErrLockedOut    = errors.New("locked out: too many failed attempts")
+	ErrForbidden    = errors.New("forbidden: role may not do this")
+)
+
+// Role is what kind of clubhouse member someone is.
+type Role string
+
+const (
+	RoleMember Role = "member"
+	RoleAdmin  Role = "admin"
+)
+
+// Operation names a guarded Door method.
+type Operation string
+
+const (
+	OpOpen Operation = "open"
+	OpLock Operation = "lock"
+)
+
+// DefaultPermissions: any member may open the door, only admins may lock it.
+var DefaultPermissions = map[Operation][]Role{
+	OpOpen: {RoleMember, RoleAdmin},
+	OpLock: {RoleAdmin},
+}
+
+// -- Hashed Credentials --
+
+const (
+	saltSize       = 16
+	hashIterations = 10_000 // slows down guessing; production code would use bcrypt or argon2
+)
+
+type credential struct {
+	salt []byte
+	hash []byte
+	role Role
+}
+
+// CredentialStore keeps salted password hashes, never the passwords themselves.
+type CredentialStore struct {
+	mu    sync.RWMutex
+	users map[string]credential
+	dummy credential // checked for unknown users so they take as long as known ones
+}
+
+func NewCredentialStore() *CredentialStore {
+	s := &CredentialStore{users: map[string]credential{}}
+	s.dummy = newCredential("not a real password", "")
+	return s
+}
+
+func newCredential(password string, role Role) credential {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return credential{salt: salt, hash: hashPassword(salt, password), role: role}
+}
+
+func hashPassword(salt []byte, password string) []byte {
+	sum := sha256.Sum256(append(append([]byte(nil), salt...), password...))
+	for i := 1; i < hashIterations; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return sum[:]
+}
+
+func (s *CredentialStore) AddUser(user, password string, role Role) error {
+	if user == "" || password == "" {
+		return fmt.Errorf("user and password must not be empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, dup := s.users[user]; dup {
+		return fmt.Errorf("user %q already exists", user)
+	}
+	s.users[user] = newCredential(password, role)
+	return nil
+}
+
+// Verify checks a password and returns the user's role.
+func (s *CredentialStore) Verify(user, password string) (Role, bool) {
+	s.mu.RLock()
+	c, known := s.users[user]
+	s.mu.RUnlock()
+	if !known {
+		c = s.dummy
+	}
+	match := subtle.ConstantTimeCompare(hashPassword(c.salt, password), c.hash) == 1
+	return c.role, known && match
+}
+
+// -- Lockout and Audit --
+
+// SecurityConfig tunes the SecurityProxy. Zero values get sensible defaults.
+type SecurityConfig struct {
+	Credentials *CredentialStore
+	Permissions map[Operation][]Role // defaults to DefaultPermissions
+	MaxFailures int                  // failed attempts before lockout; default 3
+	Cooldown    time.Duration        // how long a lockout lasts, and a failure is remembered; default 1 minute
+	Audit       *slog.Logger         // where attempts are recorded; default discards
+	Now         func() time.Time     // clock, for tests; defaults to time.Now
+}
+
+type attempts struct {
+	failures    int
+	inflight    int // guesses being checked right now; they count toward MaxFailures
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// stale reports whether a is no different from no record at all: nothing being checked,
+// no lockout, and no failure within the cooldown.
+func (a *attempts) stale(now time.Time, cooldown time.Duration) bool {
+	return a.inflight == 0 && !now.Before(a.lockedUntil) && now.Sub(a.lastFailure) >= cooldown
+}
+
+// guard holds everything the SecurityProxy checks before it lets anyone near the door.
+type guard struct {
+	cfg SecurityConfig
+
+	mu        sync.Mutex
+	attempts  map[string]*attempts // by username, including ones that don't exist
+	lastSweep time.Time
+}
+
+func newGuard(cfg SecurityConfig) *guard {
+	if cfg.Credentials == nil {
+		cfg.Credentials = NewCredentialStore()
+	}
+	if cfg.Permissions == nil {
+		cfg.Permissions = DefaultPermissions
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = time.Minute
+	}
+	if cfg.Audit == nil {
+		cfg.Audit = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &guard{cfg: cfg, attempts: map[string]*attempts{}}
+}
+
+// authorize decides whether user may perform op, and writes the decision to the audit log.
+// A guess is reserved before the slow password check and counts toward MaxFailures until
+// it's done, so a burst of concurrent wrong guesses can't sneak past the lockout.
+func (g *guard) authorize(op Operation, user, password string) error {
+	now := g.cfg.Now()
+
+	g.mu.Lock()
+	if now.Sub(g.lastSweep) >= g.cfg.Cooldown {
+		g.sweep(now)
+	}
+	a := g.attempts[user]
+	if a == nil || a.stale(now, g.cfg.Cooldown) {
+		a = &attempts{}
+		g.attempts[user] = a
+	}
+	if lockedUntil := a.lockedUntil; now.Before(lockedUntil) {
+		g.mu.Unlock()
+		g.audit(op, user, "", ErrLockedOut, slog.Time("locked_until", lockedUntil))
+		return fmt.Errorf("%w (until %s)", ErrLockedOut, lockedUntil.Format(time.TimeOnly))
+	}
+	if pending := a.failures + a.inflight; pending >= g.cfg.MaxFailures {
+		g.mu.Unlock()
+		g.audit(op, user, "", ErrLockedOut, slog.Int("pending", pending))
+		return fmt.Errorf("%w (%d attempts still being checked)", ErrLockedOut, pending)
+	}
+	a.inflight++
+	g.mu.Unlock()
+
+	role, ok := g.cfg.Credentials.Verify(user, password)
+
+	g.mu.Lock()
+	a.inflight--
+	failures := 0
+	if ok {
+		a.failures = 0
+		if a.stale(now, 0) {
+			delete(g.attempts, user) // nothing left worth remembering
+		}
+	} else {
+		a.failures++
+		a.lastFailure = now
+		failures = a.failures
+		if a.failures >= g.cfg.MaxFailures {
+			a.failures = 0
+			a.lockedUntil = now.Add(g.cfg.Cooldown)
+		}
+	}
+	g.mu.Unlock()
+
+	if !ok {
+		g.audit(op, user, "", ErrAccessDenied, slog.Int("failures", failures))
+		return ErrAccessDenied
+	}
+	if !slices.Contains(g.cfg.Permissions[op], role) {
+		g.audit(op, user, role, ErrForbidden)
+		return ErrForbidden
+	}
+	g.audit(op, user, role, nil)
+	return nil
+}
+
+// sweep forgets the records of every user who is back to a clean slate, so guessing at
+// made-up usernames can't grow the map forever. Runs at most once per cooldown. Must hold g.mu.
+func (g *guard) sweep(now time.Time) {
+	for user, a := range g.attempts {
+		if a.stale(now, g.cfg.Cooldown) {
+			delete(g.attempts, user)
+		}
+	}
+	g.lastSweep = now
+}
+
+func (g *guard) audit(op Operation, user string, role Role, err error, extra ...any) {
+	attrs := []any{slog.String("op", string(op)), slog.String("user", user)}
+	if role != "" {
+		attrs = append(attrs, slog.String("role", string(role)))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("outcome", "denied"), slog.String("reason", err.Error()))
+	} else {
+		attrs = append(attrs, slog.String("outcome", "granted"))
+	}
+	g.cfg.Audit.Info("door access", append(attrs, extra...)...)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testGuard(t *testing.T, clock *fakeClock, audit *bytes.Buffer) *guard {
+	t.Helper()
+	creds := NewCredentialStore()
+	if err := creds.AddUser("alice", "secret123", RoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if err := creds.AddUser("bob", "hunter2", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	cfg := SecurityConfig{Credentials: creds, MaxFailures: 3, Cooldown: time.Minute, Now: clock.Now}
+	if audit != nil {
+		cfg.Audit = slog.New(slog.NewJSONHandler(audit, nil))
+	}
+	return newGuard(cfg)
+}
+
+func TestCredentialStore(t *testing.T) {
+	s := NewCredentialStore()
+	s.AddUser("alice", "secret123", RoleMember)
+	if role, ok := s.Verify("alice", "secret123"); !ok || role != RoleMember {
+		t.Errorf("right password: %q, %v", role, ok)
+	}
+	for _, tt := range [][2]string{{"alice", "secret124"}, {"alice", ""}, {"mallory", "secret123"}} {
+		if _, ok := s.Verify(tt[0], tt[1]); ok {
+			t.Errorf("Verify(%q, %q) succeeded", tt[0], tt[1])
+		}
+	}
+	if err := s.AddUser("alice", "again", RoleAdmin); err == nil {
+		t.Error("added alice twice")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	clock := newFakeClock()
+	g := testGuard(t, clock, nil)
+
+	for i := range 3 {
+		if err := g.authorize(OpOpen, "alice", "guess"); !errors.Is(err, ErrAccessDenied) {
+			t.Fatalf("guess %d: got %v, want ErrAccessDenied", i+1, err)
+		}
+	}
+	if err := g.authorize(OpOpen, "alice", "secret123"); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf("the right password during lockout: got %v, want ErrLockedOut", err)
+	}
+	if err := g.authorize(OpOpen, "bob", "hunter2"); err != nil {
+		t.Fatalf("bob is locked out because of alice: %v", err)
+	}
+
+	clock.Advance(time.Minute - time.Second)
+	if err := g.authorize(OpOpen, "alice", "secret123"); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf("a second before the cooldown ends: got %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := g.authorize(OpOpen, "alice", "secret123"); err != nil {
+		t.Fatalf("after the cooldown: %v", err)
+	}
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	g := testGuard(t, newFakeClock(), nil)
+	for range 5 {
+		g.authorize(OpOpen, "alice", "guess")
+		g.authorize(OpOpen, "alice", "guess")
+		if err := g.authorize(OpOpen, "alice", "secret123"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOldFailuresAreForgotten(t *testing.T) {
+	clock := newFakeClock()
+	g := testGuard(t, clock, nil)
+	g.authorize(OpOpen, "alice", "guess")
+	g.authorize(OpOpen, "alice", "guess")
+	clock.Advance(time.Minute)
+	if err := g.authorize(OpOpen, "alice", "guess"); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("got %v, want ErrAccessDenied", err)
+	}
+	if err := g.authorize(OpOpen, "alice", "secret123"); err != nil {
+		t.Errorf("two failures a minute apart locked alice out: %v", err)
+	}
+}
+
+func (g *guard) tracked() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.attempts)
+}
+
+func TestAttemptsDoNotPileUp(t *testing.T) {
+	clock := newFakeClock()
+	g := testGuard(t, clock, nil)
+
+	g.authorize(OpOpen, "alice", "guess")
+	g.authorize(OpOpen, "alice", "secret123")
+	if n := g.tracked(); n != 0 {
+		t.Errorf("%d record(s) left after a good login, want 0", n)
+	}
+
+	for i := range 100 {
+		g.authorize(OpOpen, fmt.Sprintf("nobody-%d", i), "guess")
+	}
+	for range 3 {
+		g.authorize(OpOpen, "mallory", "guess") // locked out for a minute
+	}
+	if n := g.tracked(); n != 101 {
+		t.Fatalf("%d record(s), want 101", n)
+	}
+	clock.Advance(time.Minute)
+	g.authorize(OpOpen, "alice", "secret123")
+	if n := g.tracked(); n != 0 {
+		t.Errorf("%d record(s) left once every failure and lockout expired, want 0", n)
+	}
+}
+
+func TestConcurrentGuessesCannotBypassLockout(t *testing.T) {
+	g := testGuard(t, newFakeClock(), nil)
+
+	const guessers = 20
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		denied int
+		start  = make(chan struct{})
+	)
+	for range guessers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			err := g.authorize(OpOpen, "alice", "guess")
+			switch {
+			case errors.Is(err, ErrAccessDenied):
+				mu.Lock()
+				denied++
+				mu.Unlock()
+			case !errors.Is(err, ErrLockedOut):
+				t.Errorf("got %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// Only guesses that actually got checked against the password say ErrAccessDenied.
+	if denied > 3 {
+		t.Errorf("%d guesses were checked, MaxFailures is 3", denied)
+	}
+	if err := g.authorize(OpOpen, "alice", "secret123"); !errors.Is(err, ErrLockedOut) {
+		t.Errorf("after the burst: got %v, want ErrLockedOut", err)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	g := testGuard(t, newFakeClock(), nil)
+	for _, tt := range []struct {
+		op   Operation
+		user string
+		pass string
+		want error
+	}{
+		{OpOpen, "alice", "secret123", nil},
+		{OpLock, "alice", "secret123", ErrForbidden},
+		{OpOpen, "bob", "hunter2", nil},
+		{OpLock, "bob", "hunter2", nil},
+		{OpLock, "bob", "wrong", ErrAccessDenied},
+	} {
+		if err := g.authorize(tt.op, tt.user, tt.pass); !errors.Is(err, tt.want) {
+			t.Errorf("%s by %s: got %v, want %v", tt.op, tt.user, err, tt.want)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	g := testGuard(t, newFakeClock(), &buf)
+	g.authorize(OpOpen, "alice", "secret123")
+	g.authorize(OpLock, "alice", "secret123")
+	g.authorize(OpOpen, "mallory", "guess")
+
+	var records []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var r map[string]any
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatalf("%v: %s", err, line)
+		}
+		records = append(records, r)
+	}
+	want := []map[string]any{
+		{"op": "open", "user": "alice", "role": "member", "outcome": "granted"},
+		{"op": "lock", "user": "alice", "role": "member", "outcome": "denied", "reason": ErrForbidden.Error()},
+		{"op": "open", "user": "mallory", "outcome": "denied", "reason": ErrAccessDenied.Error(), "failures": 1.0},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d:\n%s", len(records), len(want), buf.String())
+	}
+	for i, w := range want {
+		for k, v := range w {
+			if records[i][k] != v {
+				t.Errorf("record %d: %s = %v, want %v", i, k, records[i][k], v)
+			}
+		}
+		if _, ok := w["role"]; !ok && records[i]["role"] != nil {
+			t.Errorf("record %d: role %v for an unknown user", i, records[i]["role"])
+		}
+	}
+}